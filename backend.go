@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/dynamodbstore"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/filestore"
+	"github.com/akkgr/eventstore/sqlitestore"
+	"github.com/akkgr/eventstore/subscription"
+)
+
+// config is the backend selected by the global flags.
+type config struct {
+	backend     string
+	path        string
+	endpoint    string
+	region      string
+	local       bool
+	tablePrefix string
+}
+
+func (cfg *config) register(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.backend, "backend", "dynamodb", "backend: dynamodb, sqlite or file")
+	fs.StringVar(&cfg.path, "path", "", "database file of the sqlite backend, directory of the file backend")
+	fs.StringVar(&cfg.endpoint, "endpoint", "", "DynamoDB endpoint")
+	fs.StringVar(&cfg.region, "region", "", "AWS region")
+	fs.BoolVar(&cfg.local, "local", false, "use DynamoDB Local, at localhost:8000 unless -endpoint is set")
+	fs.StringVar(&cfg.tablePrefix, "table-prefix", "", "prefix of the DynamoDB table names")
+}
+
+// backend is an opened event store backend.
+type backend struct {
+	reader eventstore.EventStoreReader
+	writer eventstore.EventStoreWriter
+	// source reads the committed events, in commit order if the backend keeps a log.
+	source subscription.Source
+	// log is true if source reads all events since the store was created.
+	log bool
+	// createTables creates the tables of the backend; onDemand only applies to DynamoDB.
+	createTables func(onDemand bool, c context.Context) error
+	close        func() error
+}
+
+func openBackend(cfg *config, c context.Context) (*backend, error) {
+	switch cfg.backend {
+	case "dynamodb":
+		var opts []dynamodbstore.Option
+		if cfg.local {
+			endpoint := cfg.endpoint
+			if endpoint == "" {
+				endpoint = "http://localhost:8000"
+			}
+			opts = append(opts, dynamodbstore.WithLocal(endpoint))
+		} else if cfg.endpoint != "" {
+			opts = append(opts, dynamodbstore.WithEndpoint(cfg.endpoint))
+		}
+		if cfg.region != "" {
+			opts = append(opts, dynamodbstore.WithRegion(cfg.region))
+		}
+		if cfg.tablePrefix != "" {
+			opts = append(opts, dynamodbstore.WithTablePrefix(cfg.tablePrefix))
+		}
+		dbc, err := dynamodbstore.NewClient(c, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{
+			reader: dbc,
+			writer: dbc,
+			source: dynamodbstore.NewStreamSource(dbc),
+			createTables: func(onDemand bool, c context.Context) error {
+				if onDemand {
+					return dynamodbstore.EnsureTables(dbc, c, dynamodbstore.WithOnDemandBilling())
+				}
+				return dynamodbstore.EnsureTables(dbc, c)
+			},
+			close: func() error { return nil },
+		}, nil
+
+	case "sqlite":
+		if cfg.path == "" {
+			return nil, fmt.Errorf("the sqlite backend needs -path")
+		}
+		s, err := sqlitestore.Open(cfg.path)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{
+			reader: s,
+			writer: s,
+			source: s,
+			log:    true,
+			createTables: func(onDemand bool, c context.Context) error {
+				return sqlitestore.CreateTables(s, c)
+			},
+			close: s.Close,
+		}, nil
+
+	case "file":
+		if cfg.path == "" {
+			return nil, fmt.Errorf("the file backend needs -path")
+		}
+		fs, err := filestore.Open(cfg.path)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{
+			reader: fs,
+			writer: fs,
+			source: fs,
+			log:    true,
+			createTables: func(onDemand bool, c context.Context) error {
+				// the segments are created by Open
+				return nil
+			},
+			close: fs.Close,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown backend %q", cfg.backend)
+	}
+}
+
+// forEachStream calls fn with the id of every aggregate of the backend.
+func (b *backend) forEachStream(fn func(id string) error, c context.Context) error {
+	if sl, ok := b.reader.(eventstore.StreamLister); ok {
+		position := ""
+		for {
+			ids, next, err := sl.ListStreams(position, c)
+			if err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if err := fn(id); err != nil {
+					return err
+				}
+			}
+			if next == "" {
+				return nil
+			}
+			position = next
+		}
+	}
+
+	if !b.log {
+		return fmt.Errorf("backend %T cannot list its streams", b.reader)
+	}
+	seen := map[string]bool{}
+	var ids []string
+	_, err := readAll(b.source, func(events []core.Event) error {
+		for _, e := range events {
+			if !seen[e.Id] {
+				seen[e.Id] = true
+				ids = append(ids, e.Id)
+			}
+		}
+		return nil
+	}, c)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// head returns the position of source after the events committed so far.
+func (b *backend) head(c context.Context) (string, error) {
+	if hs, ok := b.source.(subscription.HeadSource); ok {
+		return hs.Head(c)
+	}
+	if !b.log {
+		return "", fmt.Errorf("source %T cannot skip to its end", b.source)
+	}
+	return readAll(b.source, func([]core.Event) error { return nil }, c)
+}
+
+// readAll reads the events of a log from the start and returns the position
+// after them. A log has no events after a read that returns none, so readAll
+// must not be used with a source that can return empty pages before its end,
+// such as a DynamoDB stream.
+func readAll(source subscription.Source, fn func(events []core.Event) error, c context.Context) (string, error) {
+	position := ""
+	for {
+		events, next, err := source.Read(position, c)
+		if err != nil {
+			return position, err
+		}
+		if len(events) == 0 {
+			return next, nil
+		}
+		if err := fn(events); err != nil {
+			return position, err
+		}
+		position = next
+	}
+}
@@ -0,0 +1,38 @@
+package command
+
+import "time"
+
+// Backoff decides whether and after how long a command is retried after a
+// concurrency conflict.
+type Backoff interface {
+	// Delay returns the time to wait before a retry, starting at 1 for the
+	// first one, and false if the command must not be retried again.
+	Delay(retry int) (time.Duration, bool)
+}
+
+// ExponentialBackoff doubles the delay of every retry, starting at Initial and
+// capped at Max, for up to Retries retries.
+type ExponentialBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Retries int
+}
+
+func (b *ExponentialBackoff) Delay(retry int) (time.Duration, bool) {
+	if retry > b.Retries {
+		return 0, false
+	}
+	d := b.Initial
+	for i := 1; i < retry && d < b.Max; i++ {
+		d *= 2
+	}
+	return min(d, b.Max), true
+}
+
+func NewDefaultBackoff() Backoff {
+	return &ExponentialBackoff{
+		Initial: 10 * time.Millisecond,
+		Max:     time.Second,
+		Retries: 5,
+	}
+}
@@ -0,0 +1,91 @@
+// Package command runs commands against aggregates, retrying them when another
+// writer changes the aggregate concurrently.
+package command
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+)
+
+// Decide returns the events a command produces from the current state of an
+// aggregate. The handler sets the id and version of the events, so that a
+// retried command can decide again against a newer state.
+type Decide[T core.Aggregate] func(a T) ([]*core.Event, error)
+
+// Handler loads an aggregate, decides the events of a command and saves them.
+type Handler[T core.Aggregate] struct {
+	repository *eventstore.Repository[T]
+	backoff    Backoff
+}
+
+func NewHandler[T core.Aggregate](r *eventstore.Repository[T], b Backoff) *Handler[T] {
+	return &Handler[T]{
+		repository: r,
+		backoff:    b,
+	}
+}
+
+// Handle runs a command against an aggregate and returns the aggregate with
+// the new events applied. An aggregate without events is created.
+// If another writer appends events to the aggregate before the new events are
+// saved, the aggregate is reloaded and the command decided again, as long as
+// the backoff allows it.
+// id is the aggregate id.
+// decide decides the events of the command.
+// c is the context.
+func (h *Handler[T]) Handle(id string, decide Decide[T], c context.Context) (T, error) {
+	var zero T
+
+	for retry := 0; ; retry++ {
+		a, err := h.attempt(id, decide, c)
+		if err == nil {
+			return a, nil
+		}
+		if !errors.Is(err, core.ConcurrencyError{}) {
+			return zero, err
+		}
+
+		d, ok := h.backoff.Delay(retry + 1)
+		if !ok {
+			return zero, err
+		}
+
+		t := time.NewTimer(d)
+		select {
+		case <-c.Done():
+			t.Stop()
+			return zero, c.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func (h *Handler[T]) attempt(id string, decide Decide[T], c context.Context) (T, error) {
+	var zero T
+
+	a, err := h.repository.Load(id, c)
+	if errors.As(err, &core.EventsNotFound{}) {
+		a = h.repository.New()
+	} else if err != nil {
+		return zero, err
+	}
+
+	events, err := decide(a)
+	if err != nil {
+		return zero, err
+	}
+
+	for i, e := range events {
+		e.Id = id
+		e.Version = a.GetVersion() + i + 1
+	}
+
+	if err := h.repository.Save(a, events, c); err != nil {
+		return zero, err
+	}
+	return a, nil
+}
@@ -0,0 +1,138 @@
+package command_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/akkgr/eventstore/command"
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+func createHandler(retries int) (*command.Handler[*customer.Customer], *eventstore.EventStore) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	repo := eventstore.NewRepository(es, func() *customer.Customer {
+		return &customer.Customer{}
+	})
+	return command.NewHandler(repo, &command.ExponentialBackoff{Retries: retries}), es
+}
+
+func rename(name string) command.Decide[*customer.Customer] {
+	return func(c *customer.Customer) ([]*core.Event, error) {
+		if c.GetVersion() == 0 {
+			e, err := core.NewEvent("", 0, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: name})
+			return []*core.Event{e}, err
+		}
+		e, err := core.NewEvent("", 0, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: name, Status: c.Status})
+		return []*core.Event{e}, err
+	}
+}
+
+func TestHandleCreatesAndUpdates(t *testing.T) {
+	h, _ := createHandler(0)
+	ctx := context.Background()
+
+	if _, err := h.Handle("id", rename("John"), ctx); err != nil {
+		t.Fatal(err)
+	}
+	c, err := h.Handle("id", rename("John Doe"), ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Id != "id" || c.Version != 2 || c.Name != "John Doe" {
+		t.Errorf("unexpected customer %+v", c)
+	}
+}
+
+func TestHandleRetriesOnConflict(t *testing.T) {
+	h, es := createHandler(3)
+	ctx := context.Background()
+
+	if _, err := h.Handle("id", rename("John"), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// another writer updates the customer while the first attempt decides
+	attempts := 0
+	c, err := h.Handle("id", func(c *customer.Customer) ([]*core.Event, error) {
+		attempts++
+		if attempts == 1 {
+			e, _ := core.NewEvent("id", 2, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: "John", Status: "Active"})
+			if err := es.Publish(e, ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rename("John Doe")(c)
+	}, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if c.Version != 3 || c.Name != "John Doe" || c.Status != "Active" {
+		t.Errorf("expected the command to be decided against the newer state, got %+v", c)
+	}
+}
+
+func TestHandleGivesUpAfterRetries(t *testing.T) {
+	h, es := createHandler(2)
+	ctx := context.Background()
+
+	if _, err := h.Handle("id", rename("John"), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// another writer updates the customer during every attempt
+	attempts := 0
+	_, err := h.Handle("id", func(c *customer.Customer) ([]*core.Event, error) {
+		attempts++
+		e, _ := core.NewEvent("id", c.Version+1, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: "John"})
+		if err := es.Publish(e, ctx); err != nil {
+			t.Fatal(err)
+		}
+		return rename("John Doe")(c)
+	}, ctx)
+
+	if !errors.Is(err, core.ConcurrencyError{}) {
+		t.Errorf("expected a concurrency error, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestHandleDoesNotRetryOtherErrors(t *testing.T) {
+	h, _ := createHandler(3)
+
+	attempts := 0
+	_, err := h.Handle("id", func(c *customer.Customer) ([]*core.Event, error) {
+		attempts++
+		return nil, errors.New("some error")
+	}, context.Background())
+
+	if err == nil || attempts != 1 {
+		t.Errorf("expected a single failed attempt, got %d attempts and %v", attempts, err)
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := &command.ExponentialBackoff{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Retries: 4}
+
+	expected := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}
+	for i, e := range expected {
+		d, ok := b.Delay(i + 1)
+		if !ok || d != e {
+			t.Errorf("retry %d: expected %v, got %v %v", i+1, e, d, ok)
+		}
+	}
+	if _, ok := b.Delay(5); ok {
+		t.Error("expected no more retries")
+	}
+}
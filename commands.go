@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+	"github.com/akkgr/eventstore/subscription"
+)
+
+// maxLineSize is the maximum size of an imported line, comfortably above the
+// maximum size of a DynamoDB item.
+const maxLineSize = 16 << 20
+
+// aggregates returns the aggregate that stream show rebuilds for an entity.
+var aggregates = map[string]func() core.Aggregate{
+	"Customer": func() core.Aggregate { return &customer.Customer{} },
+}
+
+// errInconsistent reports that verify found problems.
+var errInconsistent = errors.New("inconsistent streams")
+
+type command struct {
+	backend *backend
+	stdin   io.Reader
+	stdout  io.Writer
+}
+
+func (cmd *command) eventStore(t core.Timer) *eventstore.EventStore {
+	return eventstore.NewEventStore(cmd.backend.reader, cmd.backend.writer, t)
+}
+
+// eventView is an event with its payload printed as JSON rather than base64.
+type eventView struct {
+	*core.Event
+	Payload any `json:"payload"`
+}
+
+func view(e *core.Event) eventView {
+	if json.Valid(e.Payload) {
+		return eventView{Event: e, Payload: json.RawMessage(e.Payload)}
+	}
+	return eventView{Event: e, Payload: e.Payload}
+}
+
+func (cmd *command) print(obj any) error {
+	b, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(cmd.stdout, string(b))
+	return err
+}
+
+func (cmd *command) createTables(args []string, c context.Context) error {
+	fs := flag.NewFlagSet("tables create", flag.ContinueOnError)
+	onDemand := fs.Bool("on-demand", false, "bill the DynamoDB tables on demand")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return cmd.backend.createTables(*onDemand, c)
+}
+
+func (cmd *command) show(args []string, c context.Context) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	id := args[0]
+
+	events, err := cmd.eventStore(core.NewDefaultTimer()).LoadEvents(id, 0, c)
+	if err != nil {
+		return err
+	}
+	for i := range *events {
+		if err := cmd.print(view(&(*events)[i])); err != nil {
+			return err
+		}
+	}
+
+	entity := (*events)[len(*events)-1].Entity
+	factory, ok := aggregates[entity]
+	if !ok {
+		return nil
+	}
+	a := factory()
+	for i := range *events {
+		if err := a.Apply(&(*events)[i]); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(cmd.stdout, "state:")
+	return cmd.print(a)
+}
+
+func (cmd *command) tail(args []string, c context.Context) error {
+	fs := flag.NewFlagSet("stream tail", flag.ContinueOnError)
+	all := fs.Bool("all", false, "print the events committed before the tail started")
+	entity := fs.String("entity", "", "only print the events of this entity")
+	interval := fs.Duration("interval", subscription.DefaultPollInterval, "time between polls")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	checkpoints := memorystore.NewMemoryStore()
+	if !*all {
+		position, err := cmd.backend.head(c)
+		if err != nil {
+			return err
+		}
+		if err := checkpoints.SaveCheckpoint("tail", position, c); err != nil {
+			return err
+		}
+	}
+
+	sub := subscription.NewSubscription("tail", cmd.backend.source, checkpoints,
+		subscription.WithPollInterval(*interval))
+	sub.Register(subscription.HandlerFunc(func(e *core.Event, c context.Context) error {
+		if *entity != "" && e.Entity != *entity {
+			return nil
+		}
+		b, err := json.Marshal(view(e))
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(cmd.stdout, string(b))
+		return err
+	}))
+
+	err := sub.Run(c)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func (cmd *command) export(args []string, c context.Context) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	w := cmd.stdout
+	if len(args) == 1 {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	write := func(events []core.Event) error {
+		for i := range events {
+			if err := enc.Encode(&events[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// a log holds the events in commit order, otherwise the streams are
+	// exported one after the other
+	if cmd.backend.log {
+		if _, err := readAll(cmd.backend.source, write, c); err != nil {
+			return err
+		}
+	} else {
+		es := cmd.eventStore(core.NewDefaultTimer())
+		err := cmd.backend.forEachStream(func(id string) error {
+			events, err := es.LoadEvents(id, 0, c)
+			if err != nil {
+				return err
+			}
+			return write(*events)
+		}, c)
+		if err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// importTimer stamps an imported event with the time it was created at.
+type importTimer struct {
+	created time.Time
+}
+
+func (t *importTimer) Now() time.Time {
+	return t.created
+}
+
+func (cmd *command) importEvents(args []string, c context.Context) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	r := cmd.stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	t := &importTimer{}
+	es := cmd.eventStore(t)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineSize)
+	n := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e core.Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		t.created = e.Created
+		if err := es.Publish(&e, c); err != nil {
+			return fmt.Errorf("line %d: event %d of stream %s: %w", line, e.Version, e.Id, err)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.stdout, "imported %d events\n", n)
+	return nil
+}
+
+func (cmd *command) verify(args []string, c context.Context) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+
+	streams, problems := 0, 0
+	err := cmd.backend.forEachStream(func(id string) error {
+		streams++
+		found, err := cmd.verifyStream(id, c)
+		if err != nil {
+			return err
+		}
+		for _, p := range found {
+			fmt.Fprintf(cmd.stdout, "stream %s: %s\n", id, p)
+		}
+		problems += len(found)
+		return nil
+	}, c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(cmd.stdout, "verified %d streams, found %d problems\n", streams, problems)
+	if problems > 0 {
+		return errInconsistent
+	}
+	return nil
+}
+
+// verifyStream returns the problems of a stream: versions that are missing or
+// out of order, events left after a failed append, and events that do not
+// belong to the stream.
+func (cmd *command) verifyStream(id string, c context.Context) ([]string, error) {
+	last, err := cmd.backend.reader.GetLastEvent(id, c)
+	if err != nil {
+		return nil, err
+	}
+	if last.Version == 0 {
+		return []string{"no last event"}, nil
+	}
+	events, err := cmd.backend.reader.GetEvents(id, 0, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	expected := 1
+	for i := range *events {
+		e := &(*events)[i]
+		if e.Version >= last.Version {
+			problems = append(problems, fmt.Sprintf("event %d is stored after the last event %d", e.Version, last.Version))
+			continue
+		}
+		problems = append(problems, checkEvent(id, last.Entity, e)...)
+		if e.Version != expected {
+			problems = append(problems, fmt.Sprintf("expected event %d, found %d", expected, e.Version))
+		}
+		expected = e.Version + 1
+	}
+
+	problems = append(problems, checkEvent(id, last.Entity, last)...)
+	if last.Version != expected {
+		problems = append(problems, fmt.Sprintf("expected event %d, found last event %d", expected, last.Version))
+	}
+	return problems, nil
+}
+
+// checkEvent returns the problems of an event of a stream of entity.
+func checkEvent(id string, entity string, e *core.Event) []string {
+	var problems []string
+	if e.Id != id {
+		problems = append(problems, fmt.Sprintf("event %d has id %s", e.Version, e.Id))
+	}
+	if e.Entity != entity {
+		problems = append(problems, fmt.Sprintf("event %d has entity %s, not %s", e.Version, e.Entity, entity))
+	}
+	if !json.Valid(e.Payload) {
+		problems = append(problems, fmt.Sprintf("event %d has an invalid JSON payload", e.Version))
+	}
+	return problems
+}
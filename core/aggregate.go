@@ -4,6 +4,7 @@ import "github.com/akkgr/eventstore/properties"
 
 type Aggregate interface {
 	Apply(e *Event) error
+	GetVersion() int
 }
 
 type AggregateBase struct {
@@ -18,6 +19,10 @@ func (a *AggregateBase) New(id string, entity string) {
 	a.Entity = entity
 }
 
+func (a *AggregateBase) GetVersion() int {
+	return a.Version
+}
+
 func (a *AggregateBase) SetVersion(version int) error {
 	if version != a.Version+1 {
 		return InvalidVersion{}
@@ -0,0 +1,62 @@
+package core
+
+import "context"
+
+type contextKey int
+
+const (
+	correlationIdKey contextKey = iota
+	causationIdKey
+	actorKey
+	metadataKey
+)
+
+// ContextWithCorrelationId returns a context that carries the correlation id of
+// the events published with it.
+func ContextWithCorrelationId(c context.Context, id string) context.Context {
+	return context.WithValue(c, correlationIdKey, id)
+}
+
+func CorrelationIdFromContext(c context.Context) string {
+	id, _ := c.Value(correlationIdKey).(string)
+	return id
+}
+
+// ContextWithCausationId returns a context that carries the causation id of the
+// events published with it.
+func ContextWithCausationId(c context.Context, id string) context.Context {
+	return context.WithValue(c, causationIdKey, id)
+}
+
+func CausationIdFromContext(c context.Context) string {
+	id, _ := c.Value(causationIdKey).(string)
+	return id
+}
+
+// ContextWithActor returns a context that carries the actor of the events
+// published with it.
+func ContextWithActor(c context.Context, actor string) context.Context {
+	return context.WithValue(c, actorKey, actor)
+}
+
+func ActorFromContext(c context.Context) string {
+	actor, _ := c.Value(actorKey).(string)
+	return actor
+}
+
+// ContextWithMetadata returns a context that carries a header of the events
+// published with it, in addition to the headers already carried by c.
+func ContextWithMetadata(c context.Context, key string, value string) context.Context {
+	current := MetadataFromContext(c)
+	metadata := make(map[string]string, len(current)+1)
+	for k, v := range current {
+		metadata[k] = v
+	}
+	metadata[key] = value
+	return context.WithValue(c, metadataKey, metadata)
+}
+
+func MetadataFromContext(c context.Context) map[string]string {
+	metadata, _ := c.Value(metadataKey).(map[string]string)
+	return metadata
+}
@@ -1,29 +1,95 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"time"
 )
 
 type Event struct {
-	Id      string    `json:"id"`
-	Version int       `json:"version"`
-	Entity  string    `json:"entity"`
-	Action  string    `json:"action"`
-	Created time.Time `json:"created"`
-	Payload []byte    `json:"payload"`
+	Id            string            `json:"id"`
+	Version       int               `json:"version"`
+	Entity        string            `json:"entity"`
+	Action        string            `json:"action"`
+	Created       time.Time         `json:"created"`
+	Payload       []byte            `json:"payload"`
+	SchemaVersion int               `json:"schemaVersion"`
+	CorrelationId string            `json:"correlationId,omitempty" dynamodbav:",omitempty"`
+	CausationId   string            `json:"causationId,omitempty" dynamodbav:",omitempty"`
+	Actor         string            `json:"actor,omitempty" dynamodbav:",omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty" dynamodbav:",omitempty"`
 }
 
-func NewEvent(id string, version int, entity string, action string, payload any) (*Event, error) {
+type EventOption func(*Event)
+
+// WithCorrelationId sets the id of the request or process the event is part of.
+func WithCorrelationId(id string) EventOption {
+	return func(e *Event) {
+		e.CorrelationId = id
+	}
+}
+
+// WithCausationId sets the id of the command or event that caused the event.
+func WithCausationId(id string) EventOption {
+	return func(e *Event) {
+		e.CausationId = id
+	}
+}
+
+// WithActor sets the user or service that produced the event.
+func WithActor(actor string) EventOption {
+	return func(e *Event) {
+		e.Actor = actor
+	}
+}
+
+// WithMetadata adds a header to the event.
+func WithMetadata(key string, value string) EventOption {
+	return func(e *Event) {
+		if e.Metadata == nil {
+			e.Metadata = make(map[string]string)
+		}
+		e.Metadata[key] = value
+	}
+}
+
+func NewEvent(id string, version int, entity string, action string, payload any, opts ...EventOption) (*Event, error) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
-	return &Event{
+	e := &Event{
 		Id:      id,
 		Version: version,
 		Entity:  entity,
 		Action:  action,
 		Payload: data,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// FillFromContext sets the correlation id, causation id, actor and headers of
+// the event that are not set yet from the context, see ContextWithCorrelationId.
+func (e *Event) FillFromContext(c context.Context) {
+	if e.CorrelationId == "" {
+		e.CorrelationId = CorrelationIdFromContext(c)
+	}
+	if e.CausationId == "" {
+		e.CausationId = CausationIdFromContext(c)
+	}
+	if e.Actor == "" {
+		e.Actor = ActorFromContext(c)
+	}
+	for key, value := range MetadataFromContext(c) {
+		if _, ok := e.Metadata[key]; ok {
+			continue
+		}
+		if e.Metadata == nil {
+			e.Metadata = make(map[string]string)
+		}
+		e.Metadata[key] = value
+	}
 }
@@ -1,5 +1,7 @@
 package core
 
+import "fmt"
+
 type InvalidPayload struct {
 }
 
@@ -20,3 +22,69 @@ type EventsNotFound struct {
 func (e EventsNotFound) Error() string {
 	return "events not found"
 }
+
+type InvalidBatch struct {
+}
+
+func (e InvalidBatch) Error() string {
+	return "Invalid batch"
+}
+
+type NotSupported struct {
+	Operation string
+}
+
+func (e NotSupported) Error() string {
+	return e.Operation + " not supported"
+}
+
+// ConcurrencyError is returned when events cannot be stored because another
+// writer has changed the stream since it was read.
+// It matches both ConcurrencyError and InvalidVersion with errors.Is.
+type ConcurrencyError struct {
+	StreamId string
+	Expected int
+	Actual   int
+}
+
+func (e ConcurrencyError) Error() string {
+	return fmt.Sprintf("Invalid version: expected stream %s at version %d, actual version %d", e.StreamId, e.Expected, e.Actual)
+}
+
+func (e ConcurrencyError) Is(target error) bool {
+	switch target.(type) {
+	case ConcurrencyError, *ConcurrencyError, InvalidVersion, *InvalidVersion:
+		return true
+	default:
+		return false
+	}
+}
+
+// ApplyError is returned when events were published but one of them could not
+// be applied to the aggregate. The events are committed: saving them again
+// fails with a ConcurrencyError, the aggregate has to be loaded again instead.
+type ApplyError struct {
+	StreamId string
+	Version  int
+	Err      error
+}
+
+func (e ApplyError) Error() string {
+	return fmt.Sprintf("events of stream %s are committed, but version %d cannot be applied: %v", e.StreamId, e.Version, e.Err)
+}
+
+func (e ApplyError) Unwrap() error {
+	return e.Err
+}
+
+// MissingUpcaster is returned when an event cannot be upcast to the latest
+// schema version of its action, because no upcaster converts it from Version.
+type MissingUpcaster struct {
+	Action  string
+	Version int
+	Latest  int
+}
+
+func (e MissingUpcaster) Error() string {
+	return fmt.Sprintf("no upcaster converts %s from schema version %d to the latest version %d", e.Action, e.Version, e.Latest)
+}
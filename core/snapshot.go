@@ -0,0 +1,8 @@
+package core
+
+// Snapshot is the serialized state of an aggregate at a version.
+type Snapshot struct {
+	Id      string `json:"id"`
+	Version int    `json:"version"`
+	State   []byte `json:"state"`
+}
@@ -5,7 +5,6 @@ import (
 
 	"github.com/akkgr/eventstore/core"
 	"github.com/akkgr/eventstore/properties"
-	"github.com/google/uuid"
 )
 
 type Customer struct {
@@ -34,7 +33,7 @@ func (c *Customer) customerCreated(e *core.Event) error {
 	if err := json.Unmarshal(e.Payload, &payload); err != nil {
 		return err
 	}
-	c.New(uuid.New().String(), "Customer")
+	c.New(e.Id, "Customer")
 	c.Name = payload.Name
 
 	return nil
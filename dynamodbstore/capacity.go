@@ -0,0 +1,66 @@
+package dynamodbstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ConsumedCapacity adds up the capacity units consumed by the event and
+// snapshot requests made with a context, see WithConsumedCapacity.
+// It is safe for concurrent use.
+type ConsumedCapacity struct {
+	mu     sync.Mutex
+	units  float64
+	parent *ConsumedCapacity
+}
+
+// Units returns the capacity units consumed so far.
+func (cc *ConsumedCapacity) Units() float64 {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	return cc.units
+}
+
+func (cc *ConsumedCapacity) add(units float64) {
+	cc.mu.Lock()
+	cc.units += units
+	cc.mu.Unlock()
+
+	if cc.parent != nil {
+		cc.parent.add(units)
+	}
+}
+
+type capacityKey struct{}
+
+// WithConsumedCapacity returns a context that makes the client request the
+// capacity consumed by its requests and add it to cc. The capacity is also
+// added to the ConsumedCapacity of c, if any.
+func WithConsumedCapacity(c context.Context, cc *ConsumedCapacity) context.Context {
+	if parent, ok := c.Value(capacityKey{}).(*ConsumedCapacity); ok && parent != cc {
+		cc.parent = parent
+	}
+	return context.WithValue(c, capacityKey{}, cc)
+}
+
+// returnCapacity returns whether requests made with c return their consumed capacity.
+func returnCapacity(c context.Context) types.ReturnConsumedCapacity {
+	if _, ok := c.Value(capacityKey{}).(*ConsumedCapacity); ok {
+		return types.ReturnConsumedCapacityTotal
+	}
+	return types.ReturnConsumedCapacityNone
+}
+
+// recordCapacity adds the capacity consumed by a request made with c to the
+// ConsumedCapacity of c, if any.
+func recordCapacity(c context.Context, capacity *types.ConsumedCapacity) {
+	cc, ok := c.Value(capacityKey{}).(*ConsumedCapacity)
+	if !ok || capacity == nil {
+		return
+	}
+	cc.add(aws.ToFloat64(capacity.CapacityUnits))
+}
@@ -0,0 +1,36 @@
+package dynamodbstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestConsumedCapacity(t *testing.T) {
+	c := context.Background()
+	if returnCapacity(c) != types.ReturnConsumedCapacityNone {
+		t.Error("expected no capacity to be requested without a recorder")
+	}
+	recordCapacity(c, &types.ConsumedCapacity{CapacityUnits: aws.Float64(1)})
+
+	cc := &ConsumedCapacity{}
+	c = WithConsumedCapacity(c, cc)
+	if returnCapacity(c) != types.ReturnConsumedCapacityTotal {
+		t.Error("expected the total capacity to be requested")
+	}
+	recordCapacity(c, &types.ConsumedCapacity{CapacityUnits: aws.Float64(1)})
+	recordCapacity(c, &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)})
+	recordCapacity(c, nil)
+
+	if cc.Units() != 1.5 {
+		t.Errorf("expected 1.5 units, got %v", cc.Units())
+	}
+
+	inner := &ConsumedCapacity{}
+	recordCapacity(WithConsumedCapacity(c, inner), &types.ConsumedCapacity{CapacityUnits: aws.Float64(2)})
+	if inner.Units() != 2 || cc.Units() != 3.5 {
+		t.Errorf("expected the capacity to be added to both recorders, got %v and %v", inner.Units(), cc.Units())
+	}
+}
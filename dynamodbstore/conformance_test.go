@@ -0,0 +1,33 @@
+package dynamodbstore_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/akkgr/eventstore/dynamodbstore"
+	"github.com/akkgr/eventstore/eventstoretest"
+)
+
+// The conformance suite runs against DynamoDB Local on localhost:8000,
+// see docker-compose.yml, and is skipped unless DYNAMODB_LOCAL is set.
+func TestConformance(t *testing.T) {
+	if os.Getenv("DYNAMODB_LOCAL") == "" {
+		t.Skip("DYNAMODB_LOCAL is not set")
+	}
+
+	dbc, err := dynamodbstore.NewClient(context.Background(),
+		dynamodbstore.WithLocal("http://localhost:8000"),
+		dynamodbstore.WithEntityReadLag(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dynamodbstore.EnsureTables(dbc, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eventstoretest.Run(t, func(t *testing.T) eventstoretest.Backend {
+		return dbc
+	})
+}
@@ -2,7 +2,8 @@ package dynamodbstore
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -10,29 +11,69 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
-func newTable(dbc *DynamoDBClient, tablename string, tableDesc *dynamodb.CreateTableInput) (*types.TableDescription, error) {
-	table, err := dbc.store.CreateTable(context.TODO(), tableDesc)
-	if err != nil {
-		log.Printf("Couldn't create table %v. Here's why: %v\n", tablename, err)
-		return nil, err
+// tableWaitTimeout is the maximum time to wait for a table or an index to be
+// created or deleted.
+const tableWaitTimeout = 5 * time.Minute
+
+// indexPollInterval is the time between two checks of the status of an index.
+const indexPollInterval = 5 * time.Second
+
+type tableOptions struct {
+	onDemand            bool
+	readCapacity        int64
+	writeCapacity       int64
+	ttlAttribute        string
+	pointInTimeRecovery bool
+}
+
+type TableOption func(*tableOptions)
+
+// WithOnDemandBilling creates the tables with on-demand billing instead of
+// provisioned throughput.
+func WithOnDemandBilling() TableOption {
+	return func(o *tableOptions) {
+		o.onDemand = true
 	}
-	waiter := dynamodb.NewTableExistsWaiter(dbc.store)
-	err = waiter.Wait(context.TODO(), &dynamodb.DescribeTableInput{
-		TableName: aws.String(dbc.eventsTable)}, 5*time.Minute)
-	if err != nil {
-		log.Printf("Wait for table exists failed. Here's why: %v\n", err)
+}
+
+// WithProvisionedThroughput sets the read and write capacity units of the tables
+// and their indexes. The default is 10 of each.
+func WithProvisionedThroughput(read, write int64) TableOption {
+	return func(o *tableOptions) {
+		o.readCapacity = read
+		o.writeCapacity = write
+	}
+}
+
+// WithTimeToLive enables time to live on the tables: items whose attribute holds
+// an epoch time in the past expire. Items without the attribute never expire.
+func WithTimeToLive(attribute string) TableOption {
+	return func(o *tableOptions) {
+		o.ttlAttribute = attribute
+	}
+}
+
+// WithPointInTimeRecovery enables point-in-time recovery on the tables.
+func WithPointInTimeRecovery() TableOption {
+	return func(o *tableOptions) {
+		o.pointInTimeRecovery = true
 	}
-	return table.TableDescription, nil
 }
 
-func createEventsTable(dbc *DynamoDBClient) (*types.TableDescription, error) {
-	ti := &dynamodb.CreateTableInput{
+func eventsTableDefinition(dbc *DynamoDBClient) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
 		AttributeDefinitions: []types.AttributeDefinition{{
 			AttributeName: aws.String("Id"),
 			AttributeType: types.ScalarAttributeTypeS,
 		}, {
 			AttributeName: aws.String("Version"),
 			AttributeType: types.ScalarAttributeTypeN,
+		}, {
+			AttributeName: aws.String("Entity"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}, {
+			AttributeName: aws.String(positionAttribute),
+			AttributeType: types.ScalarAttributeTypeS,
 		}},
 		KeySchema: []types.KeySchemaElement{{
 			AttributeName: aws.String("Id"),
@@ -41,42 +82,343 @@ func createEventsTable(dbc *DynamoDBClient) (*types.TableDescription, error) {
 			AttributeName: aws.String("Version"),
 			KeyType:       types.KeyTypeRange,
 		}},
-		TableName: aws.String(dbc.eventsTable),
-		ProvisionedThroughput: &types.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(10),
-			WriteCapacityUnits: aws.Int64(10),
-		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{entityIndexDefinition()},
+		TableName:              aws.String(dbc.eventsTable),
 	}
-	return newTable(dbc, dbc.eventsTable, ti)
 }
 
-func createLastEventTable(dbc *DynamoDBClient) (*types.TableDescription, error) {
-	ti := &dynamodb.CreateTableInput{
+func lastEventTableDefinition(dbc *DynamoDBClient) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
 		AttributeDefinitions: []types.AttributeDefinition{{
 			AttributeName: aws.String("Id"),
 			AttributeType: types.ScalarAttributeTypeS,
+		}, {
+			AttributeName: aws.String("Entity"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}, {
+			AttributeName: aws.String(positionAttribute),
+			AttributeType: types.ScalarAttributeTypeS,
 		}},
 		KeySchema: []types.KeySchemaElement{{
 			AttributeName: aws.String("Id"),
 			KeyType:       types.KeyTypeHash,
 		}},
-		TableName: aws.String(dbc.lastEventTable),
-		ProvisionedThroughput: &types.ProvisionedThroughput{
-			ReadCapacityUnits:  aws.Int64(10),
-			WriteCapacityUnits: aws.Int64(10),
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{entityIndexDefinition()},
+		TableName:              aws.String(dbc.lastEventTable),
+		StreamSpecification: &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeNewAndOldImages,
 		},
 	}
-	return newTable(dbc, dbc.lastEventTable, ti)
 }
 
-func CreateTables(dbc *DynamoDBClient) error {
-	_, err := createEventsTable(dbc)
+func snapshotsTableDefinition(dbc *DynamoDBClient) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{{
+			AttributeName: aws.String("Id"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}},
+		KeySchema: []types.KeySchemaElement{{
+			AttributeName: aws.String("Id"),
+			KeyType:       types.KeyTypeHash,
+		}},
+		TableName: aws.String(dbc.snapshotsTable),
+	}
+}
+
+func checkpointsTableDefinition(dbc *DynamoDBClient) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{{
+			AttributeName: aws.String("Name"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}},
+		KeySchema: []types.KeySchemaElement{{
+			AttributeName: aws.String("Name"),
+			KeyType:       types.KeyTypeHash,
+		}},
+		TableName: aws.String(dbc.checkpointsTable),
+	}
+}
+
+func projectedTableDefinition(dbc *DynamoDBClient) *dynamodb.CreateTableInput {
+	return &dynamodb.CreateTableInput{
+		AttributeDefinitions: []types.AttributeDefinition{{
+			AttributeName: aws.String("Name"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}, {
+			AttributeName: aws.String("Id"),
+			AttributeType: types.ScalarAttributeTypeS,
+		}},
+		KeySchema: []types.KeySchemaElement{{
+			AttributeName: aws.String("Name"),
+			KeyType:       types.KeyTypeHash,
+		}, {
+			AttributeName: aws.String("Id"),
+			KeyType:       types.KeyTypeRange,
+		}},
+		TableName: aws.String(dbc.projectedTable),
+	}
+}
+
+// tableDefinitions returns the definitions of all tables, billed as set by o.
+func tableDefinitions(dbc *DynamoDBClient, o *tableOptions) []*dynamodb.CreateTableInput {
+	tables := []*dynamodb.CreateTableInput{
+		eventsTableDefinition(dbc),
+		lastEventTableDefinition(dbc),
+		snapshotsTableDefinition(dbc),
+		checkpointsTableDefinition(dbc),
+		projectedTableDefinition(dbc),
+	}
+	for _, ti := range tables {
+		if o.onDemand {
+			ti.BillingMode = types.BillingModePayPerRequest
+			continue
+		}
+		ti.BillingMode = types.BillingModeProvisioned
+		ti.ProvisionedThroughput = o.throughput()
+		for i := range ti.GlobalSecondaryIndexes {
+			ti.GlobalSecondaryIndexes[i].ProvisionedThroughput = o.throughput()
+		}
+	}
+	return tables
+}
+
+func (o *tableOptions) throughput() *types.ProvisionedThroughput {
+	return &types.ProvisionedThroughput{
+		ReadCapacityUnits:  aws.Int64(o.readCapacity),
+		WriteCapacityUnits: aws.Int64(o.writeCapacity),
+	}
+}
+
+// EnsureTables creates the tables of the event store that do not exist and
+// waits until all of them are active. It adds the indexes missing from
+// existing tables, and the stream of the last event table if it is not enabled,
+// but does not change their billing.
+// It can be called again with the same options, which does nothing once the
+// tables are up to date.
+func EnsureTables(dbc *DynamoDBClient, c context.Context, opts ...TableOption) error {
+	o := &tableOptions{readCapacity: 10, writeCapacity: 10}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	for _, ti := range tableDefinitions(dbc, o) {
+		if err := ensureTable(dbc, ti, o, c); err != nil {
+			return fmt.Errorf("table %s: %w", aws.ToString(ti.TableName), err)
+		}
+	}
+	return nil
+}
+
+func ensureTable(dbc *DynamoDBClient, ti *dynamodb.CreateTableInput, o *tableOptions, c context.Context) error {
+	var notFound *types.ResourceNotFoundException
+	var inUse *types.ResourceInUseException
+
+	table := aws.ToString(ti.TableName)
+	response, err := dbc.store.DescribeTable(c, &dynamodb.DescribeTableInput{TableName: ti.TableName})
+	exists := err == nil
+	if errors.As(err, &notFound) {
+		dbc.logger.InfoContext(c, "creating table", "table", table)
+		_, err = dbc.store.CreateTable(c, ti)
+		if errors.As(err, &inUse) {
+			dbc.logger.DebugContext(c, "table created concurrently", "table", table)
+			err = nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(dbc.store)
+	err = waiter.Wait(c, &dynamodb.DescribeTableInput{TableName: ti.TableName}, tableWaitTimeout)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		dbc.logger.InfoContext(c, "table is active", "table", table)
+	}
+
+	if exists {
+		if err := addMissingIndexes(dbc, ti, response.Table, o, c); err != nil {
+			return err
+		}
+		if err := enableStream(dbc, ti, response.Table, c); err != nil {
+			return err
+		}
+	}
+	if o.ttlAttribute != "" {
+		if err := enableTimeToLive(dbc, ti.TableName, o.ttlAttribute, c); err != nil {
+			return err
+		}
+	}
+	if o.pointInTimeRecovery {
+		_, err := dbc.store.UpdateContinuousBackups(c, &dynamodb.UpdateContinuousBackupsInput{
+			TableName: ti.TableName,
+			PointInTimeRecoverySpecification: &types.PointInTimeRecoverySpecification{
+				PointInTimeRecoveryEnabled: aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return err
+		}
+		dbc.logger.InfoContext(c, "enabled point-in-time recovery", "table", table)
+	}
+	return nil
+}
+
+// addMissingIndexes creates the global secondary indexes of ti that table does
+// not have, one at a time, and waits until they are active.
+func addMissingIndexes(dbc *DynamoDBClient, ti *dynamodb.CreateTableInput, table *types.TableDescription, o *tableOptions, c context.Context) error {
+	existing := map[string]bool{}
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		existing[aws.ToString(gsi.IndexName)] = true
+	}
+	onDemand := table.BillingModeSummary != nil &&
+		table.BillingModeSummary.BillingMode == types.BillingModePayPerRequest
+
+	for _, gsi := range ti.GlobalSecondaryIndexes {
+		if existing[aws.ToString(gsi.IndexName)] {
+			continue
+		}
+
+		action := &types.CreateGlobalSecondaryIndexAction{
+			IndexName:  gsi.IndexName,
+			KeySchema:  gsi.KeySchema,
+			Projection: gsi.Projection,
+		}
+		if !onDemand {
+			action.ProvisionedThroughput = o.throughput()
+		}
+		dbc.logger.InfoContext(c, "adding index",
+			"table", aws.ToString(ti.TableName), "index", aws.ToString(gsi.IndexName))
+		_, err := dbc.store.UpdateTable(c, &dynamodb.UpdateTableInput{
+			TableName:                   ti.TableName,
+			AttributeDefinitions:        ti.AttributeDefinitions,
+			GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{{Create: action}},
+		})
+		if err != nil {
+			return fmt.Errorf("create index %s: %w", aws.ToString(gsi.IndexName), err)
+		}
+		if err := waitForIndex(dbc, ti.TableName, gsi.IndexName, c); err != nil {
+			return err
+		}
+		dbc.logger.InfoContext(c, "index is active",
+			"table", aws.ToString(ti.TableName), "index", aws.ToString(gsi.IndexName))
+	}
+	return nil
+}
+
+// enableStream enables the stream of ti on table, if table has none, and waits
+// until the table is active again. A stream with another view type is not
+// replaced, since its records would be lost to the subscriptions reading it.
+func enableStream(dbc *DynamoDBClient, ti *dynamodb.CreateTableInput, table *types.TableDescription, c context.Context) error {
+	spec := ti.StreamSpecification
+	if spec == nil || !aws.ToBool(spec.StreamEnabled) {
+		return nil
+	}
+	if current := table.StreamSpecification; current != nil && aws.ToBool(current.StreamEnabled) {
+		if current.StreamViewType != spec.StreamViewType {
+			return fmt.Errorf("stream has view type %s, expected %s", current.StreamViewType, spec.StreamViewType)
+		}
+		return nil
+	}
+
+	_, err := dbc.store.UpdateTable(c, &dynamodb.UpdateTableInput{
+		TableName:           ti.TableName,
+		StreamSpecification: spec,
+	})
 	if err != nil {
+		return fmt.Errorf("enable stream: %w", err)
+	}
+	waiter := dynamodb.NewTableExistsWaiter(dbc.store)
+	if err := waiter.Wait(c, &dynamodb.DescribeTableInput{TableName: ti.TableName}, tableWaitTimeout); err != nil {
 		return err
 	}
-	_, err = createLastEventTable(dbc)
+	dbc.logger.InfoContext(c, "enabled stream",
+		"table", aws.ToString(ti.TableName), "view_type", spec.StreamViewType)
+	return nil
+}
+
+// waitForIndex waits until the index of the table is active.
+func waitForIndex(dbc *DynamoDBClient, tableName *string, indexName *string, c context.Context) error {
+	deadline := time.Now().Add(tableWaitTimeout)
+	for {
+		response, err := dbc.store.DescribeTable(c, &dynamodb.DescribeTableInput{TableName: tableName})
+		if err != nil {
+			return err
+		}
+		for _, gsi := range response.Table.GlobalSecondaryIndexes {
+			if aws.ToString(gsi.IndexName) == aws.ToString(indexName) && gsi.IndexStatus == types.IndexStatusActive {
+				return nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("index %s is not active after %v", aws.ToString(indexName), tableWaitTimeout)
+		}
+
+		t := time.NewTimer(indexPollInterval)
+		select {
+		case <-c.Done():
+			t.Stop()
+			return c.Err()
+		case <-t.C:
+		}
+	}
+}
+
+func enableTimeToLive(dbc *DynamoDBClient, tableName *string, attribute string, c context.Context) error {
+	response, err := dbc.store.DescribeTimeToLive(c, &dynamodb.DescribeTimeToLiveInput{TableName: tableName})
+	if err != nil {
+		return err
+	}
+	if d := response.TimeToLiveDescription; d != nil &&
+		(d.TimeToLiveStatus == types.TimeToLiveStatusEnabled || d.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		return nil
+	}
+
+	_, err = dbc.store.UpdateTimeToLive(c, &dynamodb.UpdateTimeToLiveInput{
+		TableName: tableName,
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String(attribute),
+			Enabled:       aws.Bool(true),
+		},
+	})
 	if err != nil {
 		return err
 	}
+	dbc.logger.InfoContext(c, "enabled time to live",
+		"table", aws.ToString(tableName), "attribute", attribute)
 	return nil
 }
+
+// DeleteTables deletes the tables of the event store that exist and waits until
+// they are gone. It is meant for the teardown of tests.
+func DeleteTables(dbc *DynamoDBClient, c context.Context) error {
+	var notFound *types.ResourceNotFoundException
+	waiter := dynamodb.NewTableNotExistsWaiter(dbc.store)
+
+	for _, ti := range tableDefinitions(dbc, &tableOptions{}) {
+		_, err := dbc.store.DeleteTable(c, &dynamodb.DeleteTableInput{TableName: ti.TableName})
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("table %s: %w", aws.ToString(ti.TableName), err)
+		}
+
+		err = waiter.Wait(c, &dynamodb.DescribeTableInput{TableName: ti.TableName}, tableWaitTimeout)
+		if err != nil {
+			return fmt.Errorf("table %s: %w", aws.ToString(ti.TableName), err)
+		}
+		dbc.logger.InfoContext(c, "deleted table", "table", aws.ToString(ti.TableName))
+	}
+	return nil
+}
+
+// CreateTables creates the tables of the event store with provisioned
+// throughput and waits until they exist.
+//
+// Deprecated: use EnsureTables, which also succeeds if the tables exist.
+func CreateTables(dbc *DynamoDBClient, c context.Context) error {
+	return EnsureTables(dbc, c)
+}
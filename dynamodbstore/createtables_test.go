@@ -0,0 +1,100 @@
+package dynamodbstore
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+func TestTableDefinitionsBilling(t *testing.T) {
+	dbc, err := NewClient(context.Background(), WithConfig(aws.Config{Region: "eu-west-1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ti := range tableDefinitions(dbc, &tableOptions{onDemand: true}) {
+		if ti.BillingMode != types.BillingModePayPerRequest || ti.ProvisionedThroughput != nil {
+			t.Errorf("expected %s to be billed on demand", aws.ToString(ti.TableName))
+		}
+		for _, gsi := range ti.GlobalSecondaryIndexes {
+			if gsi.ProvisionedThroughput != nil {
+				t.Errorf("expected no throughput for index %s", aws.ToString(gsi.IndexName))
+			}
+		}
+	}
+
+	for _, ti := range tableDefinitions(dbc, &tableOptions{readCapacity: 5, writeCapacity: 3}) {
+		if ti.BillingMode != types.BillingModeProvisioned || aws.ToInt64(ti.ProvisionedThroughput.WriteCapacityUnits) != 3 {
+			t.Errorf("expected %s to be provisioned", aws.ToString(ti.TableName))
+		}
+		for _, gsi := range ti.GlobalSecondaryIndexes {
+			if aws.ToInt64(gsi.ProvisionedThroughput.ReadCapacityUnits) != 5 {
+				t.Errorf("expected throughput for index %s", aws.ToString(gsi.IndexName))
+			}
+		}
+	}
+}
+
+// TestEnsureTables runs against DynamoDB Local, see TestConformance.
+func TestEnsureTables(t *testing.T) {
+	if os.Getenv("DYNAMODB_LOCAL") == "" {
+		t.Skip("DYNAMODB_LOCAL is not set")
+	}
+
+	ctx := context.Background()
+	dbc, err := NewClient(ctx, WithLocal("http://localhost:8000"), WithTablePrefix(uuid.New().String()+"-"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer DeleteTables(dbc, ctx)
+
+	// a table created before the entity index existed
+	ti := eventsTableDefinition(dbc)
+	ti.GlobalSecondaryIndexes = nil
+	ti.BillingMode = types.BillingModePayPerRequest
+	if _, err := dbc.store.CreateTable(ctx, ti); err != nil {
+		t.Fatal(err)
+	}
+	// a last event table created without its stream
+	lti := lastEventTableDefinition(dbc)
+	lti.StreamSpecification = nil
+	lti.BillingMode = types.BillingModePayPerRequest
+	if _, err := dbc.store.CreateTable(ctx, lti); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := EnsureTables(dbc, ctx, WithOnDemandBilling()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response, err := dbc.store.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: ti.TableName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Table.GlobalSecondaryIndexes) != 1 {
+		t.Error("expected the entity index to be added")
+	}
+
+	response, err = dbc.store.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: lti.TableName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec := response.Table.StreamSpecification; spec == nil || !aws.ToBool(spec.StreamEnabled) ||
+		spec.StreamViewType != types.StreamViewTypeNewAndOldImages {
+		t.Errorf("expected the stream to be enabled, got %+v", spec)
+	}
+
+	if err := DeleteTables(dbc, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteTables(dbc, ctx); err != nil {
+		t.Fatal(err)
+	}
+}
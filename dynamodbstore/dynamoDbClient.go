@@ -2,104 +2,286 @@ package dynamodbstore
 
 import (
 	"context"
+	"errors"
+	"log/slog"
 	"strconv"
+	"time"
 
 	"github.com/akkgr/eventstore/core"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
 )
 
+// maxTransactItems is the maximum number of items in a TransactWriteItems call.
+const maxTransactItems = 100
+
+// maxBatchWriteItems is the maximum number of items in a BatchWriteItem call.
+const maxBatchWriteItems = 25
+
 type DynamoDBClient struct {
-	store          *dynamodb.Client
-	lastEventTable string
-	eventsTable    string
+	store            *dynamodb.Client
+	streams          *dynamodbstreams.Client
+	consistentRead   bool
+	entityReadLag    time.Duration
+	logger           *slog.Logger
+	lastEventTable   string
+	eventsTable      string
+	snapshotsTable   string
+	checkpointsTable string
+	projectedTable   string
 }
 
-func NewDynamoDBClient(c context.Context, local bool) *DynamoDBClient {
-	var cfg aws.Config
-	var client *dynamodb.Client
-	var err error
+// NewClient returns a client for the tables of the event store.
+// Unless WithConfig or WithClient is given, it loads the default AWS
+// configuration from the environment.
+func NewClient(c context.Context, opts ...Option) (*DynamoDBClient, error) {
+	o := clientOptions{
+		tables:        DefaultTableNames,
+		entityReadLag: DefaultEntityReadLag,
+		logger:        slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
-	if local {
-		cfg, err = config.LoadDefaultConfig(context.TODO(),
-			config.WithRegion("localhost"),
-			config.WithCredentialsProvider(credentials.StaticCredentialsProvider{
-				Value: aws.Credentials{
-					AccessKeyID: "abcd", SecretAccessKey: "a1b2c3", SessionToken: "",
-					Source: "Mock credentials used above for local instance",
-				},
-			}),
-		)
+	client, streams, err := newClients(&o, c)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			panic(err)
-		}
+	return &DynamoDBClient{
+		store:            client,
+		streams:          streams,
+		consistentRead:   o.consistentRead,
+		entityReadLag:    o.entityReadLag,
+		logger:           o.logger,
+		lastEventTable:   o.prefix + o.tables.LastEvent,
+		eventsTable:      o.prefix + o.tables.Events,
+		snapshotsTable:   o.prefix + o.tables.Snapshots,
+		checkpointsTable: o.prefix + o.tables.Checkpoints,
+		projectedTable:   o.prefix + o.tables.ProjectedVersions,
+	}, nil
+}
 
-		client = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
-			o.BaseEndpoint = aws.String("http://localhost:8000")
+func newClients(o *clientOptions, c context.Context) (*dynamodb.Client, *dynamodbstreams.Client, error) {
+	if o.client != nil {
+		co := o.client.Options()
+		streams := dynamodbstreams.New(dynamodbstreams.Options{
+			Region:       co.Region,
+			Credentials:  co.Credentials,
+			BaseEndpoint: co.BaseEndpoint,
+			HTTPClient:   co.HTTPClient,
 		})
+		return o.client, streams, nil
+	}
+
+	var cfg aws.Config
+	if o.config != nil {
+		cfg = o.config.Copy()
 	} else {
-		cfg, err := config.LoadDefaultConfig(c)
+		var err error
+		cfg, err = config.LoadDefaultConfig(c)
 		if err != nil {
-			panic(err)
+			return nil, nil, err
+		}
+	}
+	if o.region != "" {
+		cfg.Region = o.region
+	}
+	if o.credentials != nil {
+		cfg.Credentials = o.credentials
+	}
+	if o.maxAttempts > 0 || o.maxBackoff > 0 {
+		cfg.Retryer = func() aws.Retryer {
+			return retry.NewStandard(func(so *retry.StandardOptions) {
+				if o.maxAttempts > 0 {
+					so.MaxAttempts = o.maxAttempts
+				}
+				if o.maxBackoff > 0 {
+					so.MaxBackoff = o.maxBackoff
+				}
+			})
+		}
+	}
+	if o.retryer != nil {
+		newRetryer := cfg.Retryer
+		if newRetryer == nil {
+			newRetryer = func() aws.Retryer {
+				return retry.NewStandard()
+			}
+		}
+		cfg.Retryer = func() aws.Retryer {
+			return o.retryer(newRetryer())
 		}
-		client = dynamodb.NewFromConfig(cfg)
 	}
 
-	return &DynamoDBClient{
-		store:          client,
-		lastEventTable: "LastEvent",
-		eventsTable:    "Events",
+	client := dynamodb.NewFromConfig(cfg, func(do *dynamodb.Options) {
+		if o.endpoint != "" {
+			do.BaseEndpoint = aws.String(o.endpoint)
+		}
+	})
+	streams := dynamodbstreams.NewFromConfig(cfg, func(so *dynamodbstreams.Options) {
+		if o.endpoint != "" {
+			so.BaseEndpoint = aws.String(o.endpoint)
+		}
+	})
+	return client, streams, nil
+}
+
+// NewDynamoDBClient returns a client for the default tables, either in AWS or in
+// a DynamoDB Local instance at localhost:8000. It panics if the AWS
+// configuration cannot be loaded.
+//
+// Deprecated: use NewClient, which is configurable and returns an error.
+func NewDynamoDBClient(c context.Context, local bool) *DynamoDBClient {
+	var opts []Option
+	if local {
+		opts = append(opts, WithLocal("http://localhost:8000"))
+	}
+	dbc, err := NewClient(c, opts...)
+	if err != nil {
+		panic(err)
 	}
+	return dbc
 }
 
 func (dbc *DynamoDBClient) AppendLastEvent(e *core.Event, c context.Context) error {
-	item, err := attributevalue.MarshalMap(e)
+	item, err := marshalEvent(e)
 	if err != nil {
 		return err
 	}
 
-	_, err = dbc.store.PutItem(c, &dynamodb.PutItemInput{
+	response, err := dbc.store.PutItem(c, &dynamodb.PutItemInput{
 		TableName: aws.String(dbc.lastEventTable), Item: item,
+		ConditionExpression:                 aws.String("attribute_not_exists(Id)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		ReturnConsumedCapacity:              returnCapacity(c),
 	})
+	if err != nil {
+		return dbc.concurrencyError(err, e.Id, 0, c)
+	}
 
-	return err
+	recordCapacity(c, response.ConsumedCapacity)
+	return nil
 }
 
 func (dbc *DynamoDBClient) UpdateLastEvent(e *core.Event, ctx context.Context) error {
-	item, err := attributevalue.MarshalMap(e)
+	item, err := marshalEvent(e)
 	if err != nil {
 		return err
 	}
 
 	version := strconv.Itoa(e.Version - 1)
 
-	_, err = dbc.store.PutItem(ctx, &dynamodb.PutItemInput{
+	response, err := dbc.store.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName: aws.String(dbc.lastEventTable), Item: item,
 		ConditionExpression: aws.String("Version = :version"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
 			":version": &types.AttributeValueMemberN{Value: version},
 		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		ReturnConsumedCapacity:              returnCapacity(ctx),
 	})
+	if err != nil {
+		return dbc.concurrencyError(err, e.Id, e.Version-1, ctx)
+	}
 
-	return err
+	recordCapacity(ctx, response.ConsumedCapacity)
+	return nil
 }
 
 func (dbc *DynamoDBClient) AppendEvent(e *core.Event, ctx context.Context) error {
-	item, err := attributevalue.MarshalMap(e)
+	item, err := marshalEvent(e)
 	if err != nil {
 		return err
 	}
 
-	_, err = dbc.store.PutItem(ctx, &dynamodb.PutItemInput{
+	response, err := dbc.store.PutItem(ctx, &dynamodb.PutItemInput{
 		TableName: aws.String(dbc.eventsTable), Item: item,
+		ReturnConsumedCapacity: returnCapacity(ctx),
 	})
+	if err != nil {
+		return err
+	}
 
-	return err
+	recordCapacity(ctx, response.ConsumedCapacity)
+	return nil
+}
+
+// TransactLastEvent moves last to the events table and replaces it with e in the
+// last event table, using a single TransactWriteItems call. The transaction is
+// cancelled if the stored last event no longer has the version of last.
+func (dbc *DynamoDBClient) TransactLastEvent(last *core.Event, e *core.Event, ctx context.Context) error {
+	return dbc.AppendEvents(last, []*core.Event{e}, ctx)
+}
+
+// AppendEvents moves last, if any, and all but the final event to the events
+// table and stores the final event in the last event table, using a single
+// TransactWriteItems call. The transaction is cancelled if the stored last
+// event no longer has the version of last, or if a new aggregate already has one.
+func (dbc *DynamoDBClient) AppendEvents(last *core.Event, events []*core.Event, ctx context.Context) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	moved := events[:len(events)-1]
+	if last != nil {
+		moved = append([]*core.Event{last}, moved...)
+	}
+	if len(moved)+1 > maxTransactItems {
+		return core.InvalidBatch{}
+	}
+
+	items := make([]types.TransactWriteItem, 0, len(moved)+1)
+	for _, e := range moved {
+		item, err := marshalEvent(e)
+		if err != nil {
+			return err
+		}
+		items = append(items, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(dbc.eventsTable), Item: item,
+			},
+		})
+	}
+
+	item, err := marshalEvent(events[len(events)-1])
+	if err != nil {
+		return err
+	}
+
+	put := &types.Put{
+		TableName: aws.String(dbc.lastEventTable), Item: item,
+		ConditionExpression:                 aws.String("attribute_not_exists(Id)"),
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+	expected := 0
+	if last != nil {
+		expected = last.Version
+		put.ConditionExpression = aws.String("Version = :version")
+		put.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(last.Version)},
+		}
+	}
+	items = append(items, types.TransactWriteItem{Put: put})
+
+	response, err := dbc.store.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems:          items,
+		ReturnConsumedCapacity: returnCapacity(ctx),
+	})
+	if err != nil {
+		return dbc.concurrencyError(err, events[0].Id, expected, ctx)
+	}
+
+	for i := range response.ConsumedCapacity {
+		recordCapacity(ctx, &response.ConsumedCapacity[i])
+	}
+	return nil
 }
 
 func (dbc *DynamoDBClient) GetLastEvent(id string, c context.Context) (*core.Event, error) {
@@ -113,10 +295,13 @@ func (dbc *DynamoDBClient) GetLastEvent(id string, c context.Context) (*core.Eve
 	key := map[string]types.AttributeValue{"Id": marshaledId}
 	response, err := dbc.store.GetItem(c, &dynamodb.GetItemInput{
 		Key: key, TableName: aws.String(dbc.lastEventTable),
+		ConsistentRead:         aws.Bool(dbc.consistentRead),
+		ReturnConsumedCapacity: returnCapacity(c),
 	})
 	if err != nil {
 		return &a, err
 	}
+	recordCapacity(c, response.ConsumedCapacity)
 
 	item := core.Event{}
 	err = attributevalue.UnmarshalMap(response.Item, &item)
@@ -127,12 +312,20 @@ func (dbc *DynamoDBClient) GetLastEvent(id string, c context.Context) (*core.Eve
 }
 
 func (dbc *DynamoDBClient) GetEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	return dbc.getEvents(id, v, dbc.consistentRead, c)
+}
+
+// getEvents queries the events of id after version v, with a strongly
+// consistent read if consistent is true, whatever WithConsistentReads says.
+func (dbc *DynamoDBClient) getEvents(id string, v int, consistent bool, c context.Context) (*[]core.Event, error) {
 	var err error
 	var response *dynamodb.QueryOutput
 	var events []core.Event
 
 	queryPaginator := dynamodb.NewQueryPaginator(dbc.store, &dynamodb.QueryInput{
 		TableName:              aws.String(dbc.eventsTable),
+		ConsistentRead:         aws.Bool(consistent),
+		ReturnConsumedCapacity: returnCapacity(c),
 		KeyConditionExpression: aws.String("#pk = :pk and #sk > :sk"),
 		ExpressionAttributeNames: map[string]string{
 			"#pk": "Id",
@@ -144,10 +337,11 @@ func (dbc *DynamoDBClient) GetEvents(id string, v int, c context.Context) (*[]co
 		},
 	})
 	for queryPaginator.HasMorePages() {
-		response, err = queryPaginator.NextPage(context.TODO())
+		response, err = queryPaginator.NextPage(c)
 		if err != nil {
 			return &events, err
 		}
+		recordCapacity(c, response.ConsumedCapacity)
 		var eventPage []core.Event
 		err = attributevalue.UnmarshalListOfMaps(response.Items, &eventPage)
 		if err != nil {
@@ -158,3 +352,214 @@ func (dbc *DynamoDBClient) GetEvents(id string, v int, c context.Context) (*[]co
 
 	return &events, err
 }
+
+// ListStreams returns the ids of the aggregates after position, in no
+// particular order. A position is the last returned id; the returned position
+// is empty once all ids have been listed.
+func (dbc *DynamoDBClient) ListStreams(position string, c context.Context) ([]string, string, error) {
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(dbc.lastEventTable),
+		ProjectionExpression:     aws.String("#pk"),
+		ExpressionAttributeNames: map[string]string{"#pk": "Id"},
+		ReturnConsumedCapacity:   returnCapacity(c),
+	}
+	if position != "" {
+		input.ExclusiveStartKey = map[string]types.AttributeValue{
+			"Id": &types.AttributeValueMemberS{Value: position},
+		}
+	}
+
+	response, err := dbc.store.Scan(c, input)
+	if err != nil {
+		return nil, position, err
+	}
+	recordCapacity(c, response.ConsumedCapacity)
+
+	var items []struct{ Id string }
+	if err := attributevalue.UnmarshalListOfMaps(response.Items, &items); err != nil {
+		return nil, position, err
+	}
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.Id
+	}
+
+	next := ""
+	if key, ok := response.LastEvaluatedKey["Id"].(*types.AttributeValueMemberS); ok {
+		next = key.Value
+	}
+	return ids, next, nil
+}
+
+// SaveSnapshot stores s unless the stored snapshot of the aggregate is newer.
+func (dbc *DynamoDBClient) SaveSnapshot(s *core.Snapshot, ctx context.Context) error {
+	item, err := attributevalue.MarshalMap(s)
+	if err != nil {
+		return err
+	}
+
+	response, err := dbc.store.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(dbc.snapshotsTable), Item: item,
+		ConditionExpression: aws.String("attribute_not_exists(Id) OR Version < :version"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":version": &types.AttributeValueMemberN{Value: strconv.Itoa(s.Version)},
+		},
+		ReturnConsumedCapacity: returnCapacity(ctx),
+	})
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	recordCapacity(ctx, response.ConsumedCapacity)
+	return nil
+}
+
+func (dbc *DynamoDBClient) GetSnapshot(id string, c context.Context) (*core.Snapshot, error) {
+	s := core.Snapshot{}
+
+	marshaledId, err := attributevalue.Marshal(id)
+	if err != nil {
+		return &s, err
+	}
+
+	key := map[string]types.AttributeValue{"Id": marshaledId}
+	response, err := dbc.store.GetItem(c, &dynamodb.GetItemInput{
+		Key: key, TableName: aws.String(dbc.snapshotsTable),
+		ConsistentRead:         aws.Bool(dbc.consistentRead),
+		ReturnConsumedCapacity: returnCapacity(c),
+	})
+	if err != nil {
+		return &s, err
+	}
+	recordCapacity(c, response.ConsumedCapacity)
+
+	err = attributevalue.UnmarshalMap(response.Item, &s)
+	return &s, err
+}
+
+type checkpoint struct {
+	Name     string
+	Position string
+}
+
+func (dbc *DynamoDBClient) SaveCheckpoint(name string, position string, ctx context.Context) error {
+	item, err := attributevalue.MarshalMap(checkpoint{Name: name, Position: position})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.store.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(dbc.checkpointsTable), Item: item,
+	})
+
+	return err
+}
+
+func (dbc *DynamoDBClient) GetCheckpoint(name string, c context.Context) (string, error) {
+	marshaledName, err := attributevalue.Marshal(name)
+	if err != nil {
+		return "", err
+	}
+
+	key := map[string]types.AttributeValue{"Name": marshaledName}
+	response, err := dbc.store.GetItem(c, &dynamodb.GetItemInput{
+		Key: key, TableName: aws.String(dbc.checkpointsTable),
+		ConsistentRead: aws.Bool(dbc.consistentRead),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	item := checkpoint{}
+	err = attributevalue.UnmarshalMap(response.Item, &item)
+	return item.Position, err
+}
+
+type projectedVersion struct {
+	Name    string
+	Id      string
+	Version int
+}
+
+func (dbc *DynamoDBClient) SaveProjectedVersion(name string, id string, version int, ctx context.Context) error {
+	item, err := attributevalue.MarshalMap(projectedVersion{Name: name, Id: id, Version: version})
+	if err != nil {
+		return err
+	}
+
+	_, err = dbc.store.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(dbc.projectedTable), Item: item,
+	})
+
+	return err
+}
+
+func (dbc *DynamoDBClient) GetProjectedVersion(name string, id string, c context.Context) (int, error) {
+	key := map[string]types.AttributeValue{
+		"Name": &types.AttributeValueMemberS{Value: name},
+		"Id":   &types.AttributeValueMemberS{Value: id},
+	}
+	response, err := dbc.store.GetItem(c, &dynamodb.GetItemInput{
+		Key: key, TableName: aws.String(dbc.projectedTable),
+		ConsistentRead: aws.Bool(dbc.consistentRead),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	item := projectedVersion{}
+	err = attributevalue.UnmarshalMap(response.Item, &item)
+	return item.Version, err
+}
+
+func (dbc *DynamoDBClient) ClearProjectedVersions(name string, ctx context.Context) error {
+	queryPaginator := dynamodb.NewQueryPaginator(dbc.store, &dynamodb.QueryInput{
+		TableName:              aws.String(dbc.projectedTable),
+		KeyConditionExpression: aws.String("#pk = :pk"),
+		ProjectionExpression:   aws.String("#pk, #sk"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "Name",
+			"#sk": "Id",
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: name},
+		},
+	})
+	for queryPaginator.HasMorePages() {
+		response, err := queryPaginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < len(response.Items); i += maxBatchWriteItems {
+			var requests []types.WriteRequest
+			for _, key := range response.Items[i:min(i+maxBatchWriteItems, len(response.Items))] {
+				requests = append(requests, types.WriteRequest{
+					DeleteRequest: &types.DeleteRequest{Key: key},
+				})
+			}
+			if err := dbc.batchWrite(dbc.projectedTable, requests, ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// batchWrite writes requests to a table, retrying the unprocessed ones.
+func (dbc *DynamoDBClient) batchWrite(table string, requests []types.WriteRequest, ctx context.Context) error {
+	for len(requests) > 0 {
+		response, err := dbc.store.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{table: requests},
+		})
+		if err != nil {
+			return err
+		}
+		requests = response.UnprocessedItems[table]
+	}
+	return nil
+}
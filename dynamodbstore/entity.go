@@ -0,0 +1,160 @@
+package dynamodbstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// entityIndex is the global secondary index of the events and last event
+	// tables, keyed by Entity and Position.
+	entityIndex = "EntityIndex"
+	// positionAttribute orders the events of an entity type in entityIndex.
+	positionAttribute = "Position"
+	// entityPageSize is the maximum number of events returned by ReadByEntity.
+	entityPageSize = 100
+	// positionTimeLayout formats the creation time at the start of a position.
+	positionTimeLayout = "20060102T150405.000000000"
+)
+
+// DefaultEntityReadLag is the time ReadByEntity waits, after an event was
+// created, before it returns the event, unless WithEntityReadLag is given.
+const DefaultEntityReadLag = 5 * time.Second
+
+// entityPosition returns the position of an event among the events of its
+// entity type: its creation time, with a fixed width so that positions sort
+// like times, followed by its aggregate id and version.
+func entityPosition(e *core.Event) string {
+	return fmt.Sprintf("%s#%s#%010d", e.Created.UTC().Format(positionTimeLayout), e.Id, e.Version)
+}
+
+// entityHorizon returns the position before which ReadByEntity returns events
+// at time now: every position of an event created lag before now or earlier
+// sorts before it, every later one after it.
+func entityHorizon(now time.Time, lag time.Duration) string {
+	// '$' sorts right after the '#' that follows the time in a position
+	return now.Add(-lag).UTC().Format(positionTimeLayout) + "$"
+}
+
+// marshalEvent marshals an event with its entity position. Events without an
+// entity type are left out of entityIndex, whose keys cannot be empty.
+func marshalEvent(e *core.Event) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(e)
+	if err != nil {
+		return nil, err
+	}
+	if e.Entity == "" {
+		delete(item, "Entity")
+	} else {
+		item[positionAttribute] = &types.AttributeValueMemberS{Value: entityPosition(e)}
+	}
+	return item, nil
+}
+
+// ReadByEntity returns the events of all aggregates of an entity type after
+// position, ordered by the time they were created, and the position of the last
+// returned event. The empty position is the start of the entity's events.
+// Positions are creation times, stamped by the publishers, so an event can be
+// committed, and become visible in entityIndex, which is eventually consistent,
+// after events created later. ReadByEntity holds back the events created within
+// the read lag before now, see WithEntityReadLag, and never returns a position
+// past that horizon: no event is skipped as long as every event is committed
+// and indexed within the read lag of its creation time on the reader's clock.
+func (dbc *DynamoDBClient) ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error) {
+	horizon := entityHorizon(time.Now(), dbc.entityReadLag)
+	if position >= horizon {
+		return &[]core.Event{}, position, nil
+	}
+
+	events, err := dbc.queryEntity(dbc.eventsTable, entity, position, horizon, c)
+	if err != nil {
+		return nil, position, err
+	}
+	lastEvents, err := dbc.queryEntity(dbc.lastEventTable, entity, position, horizon, c)
+	if err != nil {
+		return nil, position, err
+	}
+
+	// merge the events of both tables, an event that is being moved to the
+	// events table may be read from both
+	result := []core.Event{}
+	for len(result) < entityPageSize && (len(events) > 0 || len(lastEvents) > 0) {
+		var next entityEvent
+		switch {
+		case len(lastEvents) == 0 || len(events) > 0 && events[0].Position <= lastEvents[0].Position:
+			next, events = events[0], events[1:]
+		default:
+			next, lastEvents = lastEvents[0], lastEvents[1:]
+		}
+		if next.Position == position {
+			continue
+		}
+		result = append(result, next.Event)
+		position = next.Position
+	}
+
+	return &result, position, nil
+}
+
+type entityEvent struct {
+	core.Event
+	Position string
+}
+
+// queryEntity returns a page of the events of entity in table from position,
+// which is included, to horizon, which no position matches.
+func (dbc *DynamoDBClient) queryEntity(table string, entity string, position string, horizon string, c context.Context) ([]entityEvent, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(table),
+		IndexName:              aws.String(entityIndex),
+		KeyConditionExpression: aws.String("#pk = :pk and #sk < :to"),
+		ExpressionAttributeNames: map[string]string{
+			"#pk": "Entity",
+			"#sk": positionAttribute,
+		},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: entity},
+			":to": &types.AttributeValueMemberS{Value: horizon},
+		},
+		Limit:                  aws.Int32(entityPageSize),
+		ReturnConsumedCapacity: returnCapacity(c),
+	}
+	if position != "" {
+		input.KeyConditionExpression = aws.String("#pk = :pk and #sk BETWEEN :from AND :to")
+		input.ExpressionAttributeValues[":from"] = &types.AttributeValueMemberS{Value: position}
+	}
+
+	response, err := dbc.store.Query(c, input)
+	if err != nil {
+		return nil, err
+	}
+	recordCapacity(c, response.ConsumedCapacity)
+
+	var events []entityEvent
+	err = attributevalue.UnmarshalListOfMaps(response.Items, &events)
+	return events, err
+}
+
+// entityIndexDefinition returns the definition of entityIndex.
+func entityIndexDefinition() types.GlobalSecondaryIndex {
+	return types.GlobalSecondaryIndex{
+		IndexName: aws.String(entityIndex),
+		KeySchema: []types.KeySchemaElement{{
+			AttributeName: aws.String("Entity"),
+			KeyType:       types.KeyTypeHash,
+		}, {
+			AttributeName: aws.String(positionAttribute),
+			KeyType:       types.KeyTypeRange,
+		}},
+		Projection: &types.Projection{
+			ProjectionType: types.ProjectionTypeAll,
+		},
+	}
+}
@@ -0,0 +1,23 @@
+package dynamodbstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+func TestEntityHorizon(t *testing.T) {
+	now := time.Date(2000, 3, 15, 12, 0, 0, 0, time.UTC)
+	horizon := entityHorizon(now, 5*time.Second)
+
+	settled := entityPosition(&core.Event{Id: "id", Version: 1, Created: now.Add(-5 * time.Second)})
+	if settled >= horizon {
+		t.Errorf("expected %s to be before the horizon %s", settled, horizon)
+	}
+
+	recent := entityPosition(&core.Event{Id: "id", Version: 1, Created: now.Add(-5*time.Second + time.Nanosecond)})
+	if recent <= horizon {
+		t.Errorf("expected %s to be after the horizon %s", recent, horizon)
+	}
+}
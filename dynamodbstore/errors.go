@@ -0,0 +1,72 @@
+package dynamodbstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// concurrencyError maps a failed condition on the last event of a stream to a
+// core.ConcurrencyError. The actual version is read from the item returned with
+// the failure, it is 0 if the stream has no last event.
+//
+// A write that races with a transaction of another publisher of the stream
+// fails with a TransactionConflict instead, which the SDK does not retry. It is
+// a core.ConcurrencyError too, but DynamoDB returns no item with it, so its
+// actual version is left 0: the caller has to load the stream again anyway.
+func concurrencyError(err error, id string, expected int) error {
+	if err == nil {
+		return nil
+	}
+
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return core.ConcurrencyError{StreamId: id, Expected: expected, Actual: itemVersion(ccf.Item)}
+	}
+
+	var tc *types.TransactionConflictException
+	if errors.As(err, &tc) {
+		return core.ConcurrencyError{StreamId: id, Expected: expected}
+	}
+
+	var tce *types.TransactionCanceledException
+	if errors.As(err, &tce) {
+		for _, reason := range tce.CancellationReasons {
+			if aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				return core.ConcurrencyError{StreamId: id, Expected: expected, Actual: itemVersion(reason.Item)}
+			}
+		}
+		// all items of a transaction belong to the stream, so a conflict on
+		// any of them, usually the last event, is a concurrent publish
+		for _, reason := range tce.CancellationReasons {
+			if aws.ToString(reason.Code) == "TransactionConflict" {
+				return core.ConcurrencyError{StreamId: id, Expected: expected}
+			}
+		}
+	}
+
+	return err
+}
+
+// concurrencyError maps err like concurrencyError and logs the conflicts.
+func (dbc *DynamoDBClient) concurrencyError(err error, id string, expected int, c context.Context) error {
+	err = concurrencyError(err, id, expected)
+	var ce core.ConcurrencyError
+	if errors.As(err, &ce) {
+		dbc.logger.DebugContext(c, "version conflict",
+			"stream_id", id, "expected_version", expected, "actual_version", ce.Actual)
+	}
+	return err
+}
+
+func itemVersion(item map[string]types.AttributeValue) int {
+	e := core.Event{}
+	if err := attributevalue.UnmarshalMap(item, &e); err != nil {
+		return 0
+	}
+	return e.Version
+}
@@ -0,0 +1,110 @@
+package dynamodbstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestConcurrencyErrorFromConditionalCheck(t *testing.T) {
+	err := concurrencyError(&types.ConditionalCheckFailedException{
+		Item: map[string]types.AttributeValue{
+			"Id":      &types.AttributeValueMemberS{Value: "id"},
+			"Version": &types.AttributeValueMemberN{Value: "4"},
+		},
+	}, "id", 2)
+
+	var ce core.ConcurrencyError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a concurrency error, got %v", err)
+	}
+	if ce != (core.ConcurrencyError{StreamId: "id", Expected: 2, Actual: 4}) {
+		t.Errorf("unexpected concurrency error %+v", ce)
+	}
+}
+
+func TestConcurrencyErrorFromCancelledTransaction(t *testing.T) {
+	err := concurrencyError(&types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{
+			{Code: aws.String("None")},
+			{Code: aws.String("ConditionalCheckFailed")},
+		},
+	}, "id", 0)
+
+	var ce core.ConcurrencyError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a concurrency error, got %v", err)
+	}
+	if ce != (core.ConcurrencyError{StreamId: "id", Expected: 0, Actual: 0}) {
+		t.Errorf("unexpected concurrency error %+v", ce)
+	}
+}
+
+func TestConcurrencyErrorFromTransactionConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"CancelledTransaction", &types.TransactionCanceledException{
+			CancellationReasons: []types.CancellationReason{
+				{Code: aws.String("None")},
+				{Code: aws.String("TransactionConflict")},
+			},
+		}},
+		{"Put", &types.TransactionConflictException{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ce core.ConcurrencyError
+			if !errors.As(concurrencyError(tt.err, "id", 3), &ce) {
+				t.Fatalf("expected a concurrency error, got %v", tt.err)
+			}
+			if ce != (core.ConcurrencyError{StreamId: "id", Expected: 3, Actual: 0}) {
+				t.Errorf("unexpected concurrency error %+v", ce)
+			}
+		})
+	}
+}
+
+func TestConcurrencyErrorKeepsOtherErrors(t *testing.T) {
+	other := errors.New("some error")
+	if err := concurrencyError(other, "id", 1); err != other {
+		t.Errorf("expected the error to be kept, got %v", err)
+	}
+
+	cancelled := &types.TransactionCanceledException{
+		CancellationReasons: []types.CancellationReason{{Code: aws.String("ThrottlingError")}},
+	}
+	if err := concurrencyError(cancelled, "id", 1); err != error(cancelled) {
+		t.Errorf("expected the error to be kept, got %v", err)
+	}
+	if concurrencyError(nil, "id", 1) != nil {
+		t.Error("expected no error")
+	}
+}
+
+func TestConcurrencyErrorLogged(t *testing.T) {
+	var log bytes.Buffer
+	dbc := &DynamoDBClient{logger: slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	ctx := context.Background()
+
+	if err := dbc.concurrencyError(errors.New("some error"), "id", 1, ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	if log.Len() != 0 {
+		t.Errorf("expected other errors not to be logged, got %q", log.String())
+	}
+
+	dbc.concurrencyError(&types.ConditionalCheckFailedException{}, "id", 1, ctx)
+	if !strings.Contains(log.String(), `level=DEBUG msg="version conflict" stream_id=id expected_version=1 actual_version=0`) {
+		t.Errorf("expected the conflict to be logged, got %q", log.String())
+	}
+}
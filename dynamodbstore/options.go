@@ -0,0 +1,172 @@
+package dynamodbstore
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// TableNames are the names of the tables used by the client.
+// An empty name keeps the default name of the table.
+type TableNames struct {
+	LastEvent         string
+	Events            string
+	Snapshots         string
+	Checkpoints       string
+	ProjectedVersions string
+}
+
+// DefaultTableNames are the table names used unless WithTableNames is given.
+var DefaultTableNames = TableNames{
+	LastEvent:         "LastEvent",
+	Events:            "Events",
+	Snapshots:         "Snapshots",
+	Checkpoints:       "Checkpoints",
+	ProjectedVersions: "ProjectedVersions",
+}
+
+type clientOptions struct {
+	tables         TableNames
+	prefix         string
+	endpoint       string
+	region         string
+	credentials    aws.CredentialsProvider
+	config         *aws.Config
+	client         *dynamodb.Client
+	maxAttempts    int
+	maxBackoff     time.Duration
+	retryer        func(aws.Retryer) aws.Retryer
+	consistentRead bool
+	entityReadLag  time.Duration
+	logger         *slog.Logger
+}
+
+type Option func(*clientOptions)
+
+// WithTableNames sets the names of the tables.
+func WithTableNames(names TableNames) Option {
+	return func(o *clientOptions) {
+		if names.LastEvent != "" {
+			o.tables.LastEvent = names.LastEvent
+		}
+		if names.Events != "" {
+			o.tables.Events = names.Events
+		}
+		if names.Snapshots != "" {
+			o.tables.Snapshots = names.Snapshots
+		}
+		if names.Checkpoints != "" {
+			o.tables.Checkpoints = names.Checkpoints
+		}
+		if names.ProjectedVersions != "" {
+			o.tables.ProjectedVersions = names.ProjectedVersions
+		}
+	}
+}
+
+// WithTablePrefix prefixes the names of all tables, so that several
+// environments can share an account.
+func WithTablePrefix(prefix string) Option {
+	return func(o *clientOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithEndpoint sends the requests to endpoint instead of the AWS endpoint of the region.
+func WithEndpoint(endpoint string) Option {
+	return func(o *clientOptions) {
+		o.endpoint = endpoint
+	}
+}
+
+// WithRegion sets the AWS region.
+func WithRegion(region string) Option {
+	return func(o *clientOptions) {
+		o.region = region
+	}
+}
+
+// WithCredentials sets the credentials used to sign the requests.
+func WithCredentials(p aws.CredentialsProvider) Option {
+	return func(o *clientOptions) {
+		o.credentials = p
+	}
+}
+
+// WithLocal connects to a DynamoDB Local instance at endpoint, which accepts
+// any region and credentials.
+func WithLocal(endpoint string) Option {
+	return func(o *clientOptions) {
+		o.endpoint = endpoint
+		o.region = "localhost"
+		o.credentials = credentials.NewStaticCredentialsProvider("local", "local", "")
+	}
+}
+
+// WithConfig uses cfg instead of loading the default AWS configuration.
+func WithConfig(cfg aws.Config) Option {
+	return func(o *clientOptions) {
+		o.config = &cfg
+	}
+}
+
+// WithClient uses an existing DynamoDB client, and a DynamoDB Streams client
+// with the same region, credentials and endpoint. The configuration, endpoint,
+// region, credentials and retry options, including WithRetryer, are ignored.
+func WithClient(client *dynamodb.Client) Option {
+	return func(o *clientOptions) {
+		o.client = client
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts of a request, including
+// the first one.
+func WithMaxAttempts(n int) Option {
+	return func(o *clientOptions) {
+		o.maxAttempts = n
+	}
+}
+
+// WithMaxBackoff sets the maximum delay between two attempts of a request.
+func WithMaxBackoff(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.maxBackoff = d
+	}
+}
+
+// WithRetryer wraps the retryer of the requests, after the other retry options
+// are applied, for example to count the retries.
+func WithRetryer(wrap func(aws.Retryer) aws.Retryer) Option {
+	return func(o *clientOptions) {
+		o.retryer = wrap
+	}
+}
+
+// WithConsistentReads makes reads from the tables strongly consistent.
+// Reads from the entity index are always eventually consistent.
+func WithConsistentReads(consistent bool) Option {
+	return func(o *clientOptions) {
+		o.consistentRead = consistent
+	}
+}
+
+// WithEntityReadLag sets the time ReadByEntity waits, after an event was
+// created, before it returns the event, DefaultEntityReadLag by default. It
+// must exceed the time between the creation and the commit of an event, the
+// skew between the clocks of the publishers and the readers, and the delay of
+// the entity index.
+func WithEntityReadLag(d time.Duration) Option {
+	return func(o *clientOptions) {
+		o.entityReadLag = d
+	}
+}
+
+// WithLogger sets the logger of the client, slog.Default() by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *clientOptions) {
+		o.logger = l
+	}
+}
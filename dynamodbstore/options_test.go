@@ -0,0 +1,96 @@
+package dynamodbstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+func TestNewClientTableNames(t *testing.T) {
+	dbc, err := NewClient(context.Background(),
+		WithConfig(aws.Config{Region: "eu-west-1"}),
+		WithTableNames(TableNames{Events: "History"}),
+		WithTablePrefix("test-"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dbc.eventsTable != "test-History" {
+		t.Errorf("expected test-History, got %s", dbc.eventsTable)
+	}
+	if dbc.lastEventTable != "test-LastEvent" {
+		t.Errorf("expected test-LastEvent, got %s", dbc.lastEventTable)
+	}
+	if dbc.consistentRead {
+		t.Error("expected eventually consistent reads")
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	dbc, err := NewClient(context.Background(),
+		WithConfig(aws.Config{Region: "eu-west-1"}),
+		WithLocal("http://localhost:8000"),
+		WithMaxAttempts(7),
+		WithConsistentReads(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	o := dbc.store.Options()
+	if aws.ToString(o.BaseEndpoint) != "http://localhost:8000" {
+		t.Errorf("expected the local endpoint, got %s", aws.ToString(o.BaseEndpoint))
+	}
+	if o.Region != "localhost" {
+		t.Errorf("expected the localhost region, got %s", o.Region)
+	}
+	if o.Retryer.MaxAttempts() != 7 {
+		t.Errorf("expected 7 attempts, got %d", o.Retryer.MaxAttempts())
+	}
+	if aws.ToString(dbc.streams.Options().BaseEndpoint) != "http://localhost:8000" {
+		t.Error("expected the streams client to use the local endpoint")
+	}
+	if !dbc.consistentRead {
+		t.Error("expected consistent reads")
+	}
+}
+
+func TestNewClientWithClient(t *testing.T) {
+	client := dynamodb.New(dynamodb.Options{Region: "eu-west-1"})
+	dbc, err := NewClient(context.Background(), WithClient(client))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dbc.store != client {
+		t.Error("expected the given client")
+	}
+	if dbc.streams.Options().Region != "eu-west-1" {
+		t.Error("expected the streams client to use the region of the given client")
+	}
+}
+
+func TestNewClientRetryer(t *testing.T) {
+	var wrapped aws.Retryer
+	dbc, err := NewClient(context.Background(),
+		WithConfig(aws.Config{Region: "eu-west-1"}),
+		WithMaxAttempts(5),
+		WithRetryer(func(r aws.Retryer) aws.Retryer {
+			wrapped = r
+			return r
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if wrapped == nil || wrapped.MaxAttempts() != 5 {
+		t.Fatal("expected the configured retryer to be wrapped")
+	}
+	if dbc.store.Options().Retryer.MaxAttempts() != 5 {
+		t.Errorf("expected 5 attempts, got %d", dbc.store.Options().Retryer.MaxAttempts())
+	}
+}
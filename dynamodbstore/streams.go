@@ -0,0 +1,283 @@
+package dynamodbstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// streamPosition is the position of a StreamSource, encoded as JSON.
+type streamPosition struct {
+	// Sequences holds the sequence number of the last record read from every shard.
+	Sequences map[string]string `json:"sequences,omitempty"`
+	// Finished holds the closed shards that were read to the end.
+	Finished map[string]bool `json:"finished,omitempty"`
+	// Latest holds the shards to read from their latest record, rather than
+	// from their oldest one, until a record has been read from them.
+	Latest map[string]bool `json:"latest,omitempty"`
+}
+
+type shardIterator struct {
+	sequence string
+	iterator string
+}
+
+// StreamSource reads the events committed to the event store from the stream
+// of the last event table. Every record of the stream is a new last event; the
+// events appended together with it, by a batch, are read from the events table.
+// Events are returned in version order per aggregate, as the records of an
+// aggregate are kept in order within a shard and parent shards are read before
+// their children.
+// A StreamSource is not safe for concurrent use.
+type StreamSource struct {
+	dbc       *DynamoDBClient
+	streamArn string
+	iterators map[string]shardIterator
+}
+
+func NewStreamSource(dbc *DynamoDBClient) *StreamSource {
+	return &StreamSource{
+		dbc:       dbc,
+		iterators: make(map[string]shardIterator),
+	}
+}
+
+// Read returns the events of one page of records of every readable shard.
+func (s *StreamSource) Read(position string, c context.Context) ([]core.Event, string, error) {
+	pos := streamPosition{}
+	if position != "" {
+		if err := json.Unmarshal([]byte(position), &pos); err != nil {
+			return nil, position, err
+		}
+	}
+
+	shards, err := s.shards(c)
+	if err != nil {
+		return nil, position, err
+	}
+
+	next := streamPosition{
+		Sequences: make(map[string]string),
+		Finished:  make(map[string]bool),
+		Latest:    make(map[string]bool),
+	}
+	listed := make(map[string]bool)
+	for _, shard := range shards {
+		id := aws.ToString(shard.ShardId)
+		listed[id] = true
+		if sequence, ok := pos.Sequences[id]; ok {
+			next.Sequences[id] = sequence
+		}
+		if pos.Finished[id] {
+			next.Finished[id] = true
+		}
+		if pos.Latest[id] {
+			next.Latest[id] = true
+		}
+	}
+
+	var events []core.Event
+	for _, shard := range shards {
+		id := aws.ToString(shard.ShardId)
+		parent := aws.ToString(shard.ParentShardId)
+		if next.Finished[id] || parent != "" && listed[parent] && !next.Finished[parent] {
+			continue
+		}
+
+		shardEvents, err := s.readShard(id, &next, c)
+		if err != nil {
+			return nil, position, err
+		}
+		events = append(events, shardEvents...)
+	}
+
+	encoded, err := json.Marshal(next)
+	if err != nil {
+		return nil, position, err
+	}
+	return events, string(encoded), nil
+}
+
+// Head returns the position after the records of the stream, without reading
+// them: closed shards are finished and open shards are read from their latest
+// record. Until a record is read from an open shard, the position only holds
+// in the StreamSource that returned it, which keeps the shard iterator; another
+// StreamSource skips the records committed in between.
+func (s *StreamSource) Head(c context.Context) (string, error) {
+	shards, err := s.shards(c)
+	if err != nil {
+		return "", err
+	}
+
+	pos := streamPosition{
+		Finished: make(map[string]bool),
+		Latest:   make(map[string]bool),
+	}
+	for _, shard := range shards {
+		id := aws.ToString(shard.ShardId)
+		if shard.SequenceNumberRange != nil && shard.SequenceNumberRange.EndingSequenceNumber != nil {
+			pos.Finished[id] = true
+		} else {
+			pos.Latest[id] = true
+		}
+	}
+
+	encoded, err := json.Marshal(pos)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// shards returns all shards of the stream of the last event table.
+func (s *StreamSource) shards(c context.Context) ([]types.Shard, error) {
+	if s.streamArn == "" {
+		table, err := s.dbc.store.DescribeTable(c, &dynamodb.DescribeTableInput{
+			TableName: aws.String(s.dbc.lastEventTable),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if table.Table.LatestStreamArn == nil {
+			return nil, fmt.Errorf("table %s has no stream", s.dbc.lastEventTable)
+		}
+		s.streamArn = aws.ToString(table.Table.LatestStreamArn)
+	}
+
+	var shards []types.Shard
+	var start *string
+	for {
+		response, err := s.dbc.streams.DescribeStream(c, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(s.streamArn),
+			ExclusiveStartShardId: start,
+		})
+		if err != nil {
+			return nil, err
+		}
+		shards = append(shards, response.StreamDescription.Shards...)
+		start = response.StreamDescription.LastEvaluatedShardId
+		if start == nil {
+			return shards, nil
+		}
+	}
+}
+
+// readShard reads one page of records of a shard and updates pos.
+func (s *StreamSource) readShard(id string, pos *streamPosition, c context.Context) ([]core.Event, error) {
+	sequence := pos.Sequences[id]
+	iterator, ok := s.iterators[id]
+	if !ok || iterator.sequence != sequence {
+		input := &dynamodbstreams.GetShardIteratorInput{
+			StreamArn:         aws.String(s.streamArn),
+			ShardId:           aws.String(id),
+			ShardIteratorType: types.ShardIteratorTypeTrimHorizon,
+		}
+		if sequence != "" {
+			input.ShardIteratorType = types.ShardIteratorTypeAfterSequenceNumber
+			input.SequenceNumber = aws.String(sequence)
+		} else if pos.Latest[id] {
+			input.ShardIteratorType = types.ShardIteratorTypeLatest
+		}
+		response, err := s.dbc.streams.GetShardIterator(c, input)
+		if err != nil {
+			return nil, err
+		}
+		iterator = shardIterator{sequence: sequence, iterator: aws.ToString(response.ShardIterator)}
+	}
+
+	response, err := s.dbc.streams.GetRecords(c, &dynamodbstreams.GetRecordsInput{
+		ShardIterator: aws.String(iterator.iterator),
+	})
+	if err != nil {
+		var expired *types.ExpiredIteratorException
+		if errors.As(err, &expired) {
+			delete(s.iterators, id)
+		}
+		return nil, err
+	}
+
+	var events []core.Event
+	for _, record := range response.Records {
+		recordEvents, err := s.recordEvents(record, c)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, recordEvents...)
+		sequence = aws.ToString(record.Dynamodb.SequenceNumber)
+	}
+
+	if sequence != "" {
+		pos.Sequences[id] = sequence
+	}
+	if response.NextShardIterator == nil {
+		pos.Finished[id] = true
+		delete(s.iterators, id)
+	} else {
+		s.iterators[id] = shardIterator{sequence: sequence, iterator: aws.ToString(response.NextShardIterator)}
+	}
+
+	return events, nil
+}
+
+// recordEvents returns the events committed by a record of the last event
+// table: the new last event, preceded by the events appended with it.
+func (s *StreamSource) recordEvents(record types.Record, c context.Context) ([]core.Event, error) {
+	if record.Dynamodb == nil || record.Dynamodb.NewImage == nil {
+		return nil, nil
+	}
+
+	last, err := unmarshalStreamEvent(record.Dynamodb.NewImage)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := core.Event{}
+	if record.Dynamodb.OldImage != nil {
+		if previous, err = unmarshalStreamEvent(record.Dynamodb.OldImage); err != nil {
+			return nil, err
+		}
+	}
+	if last.Version <= previous.Version {
+		return nil, nil
+	}
+
+	// the events of a batch are written in the same transaction as the last
+	// event, so a strongly consistent read sees them once the record exists;
+	// an eventually consistent one might not, and fail the subscription
+	var events []core.Event
+	if last.Version > previous.Version+1 {
+		stored, err := s.dbc.getEvents(last.Id, previous.Version, true, c)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range *stored {
+			if e.Version < last.Version {
+				events = append(events, e)
+			}
+		}
+		if len(events) != last.Version-previous.Version-1 {
+			return nil, fmt.Errorf("events %d to %d of %s are not readable yet",
+				previous.Version+1, last.Version-1, last.Id)
+		}
+	}
+
+	return append(events, last), nil
+}
+
+func unmarshalStreamEvent(image map[string]types.AttributeValue) (core.Event, error) {
+	e := core.Event{}
+	item, err := attributevalue.FromDynamoDBStreamsMap(image)
+	if err != nil {
+		return e, err
+	}
+	err = attributevalue.UnmarshalMap(item, &e)
+	return e, err
+}
@@ -0,0 +1,58 @@
+package dynamodbstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+func TestRecordEventsReadsConsistently(t *testing.T) {
+	var consistent []bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var query struct{ ConsistentRead bool }
+		json.NewDecoder(r.Body).Decode(&query)
+		consistent = append(consistent, query.ConsistentRead)
+
+		// the events of the batch, before the last event
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"Count":2,"Items":[` +
+			`{"Id":{"S":"id"},"Version":{"N":"2"}},` +
+			`{"Id":{"S":"id"},"Version":{"N":"3"}}]}`))
+	}))
+	defer srv.Close()
+
+	dbc, err := NewClient(context.Background(),
+		WithConfig(aws.Config{Region: "eu-west-1", Credentials: aws.AnonymousCredentials{}}),
+		WithEndpoint(srv.URL),
+		WithConsistentReads(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := types.Record{Dynamodb: &types.StreamRecord{
+		OldImage: map[string]types.AttributeValue{
+			"Id":      &types.AttributeValueMemberS{Value: "id"},
+			"Version": &types.AttributeValueMemberN{Value: "1"},
+		},
+		NewImage: map[string]types.AttributeValue{
+			"Id":      &types.AttributeValueMemberS{Value: "id"},
+			"Version": &types.AttributeValueMemberN{Value: "4"},
+		},
+	}}
+	events, err := NewStreamSource(dbc).recordEvents(record, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[0].Version != 2 || events[2].Version != 4 {
+		t.Errorf("expected events 2 to 4, got %+v", events)
+	}
+	if len(consistent) != 1 || !consistent[0] {
+		t.Errorf("expected a strongly consistent query, got %v", consistent)
+	}
+}
@@ -0,0 +1,122 @@
+package dynamodbstore_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/dynamodbstore"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/subscription"
+	"github.com/google/uuid"
+)
+
+// TestStreamSource runs against DynamoDB Local, see TestConformance.
+func TestStreamSource(t *testing.T) {
+	if os.Getenv("DYNAMODB_LOCAL") == "" {
+		t.Skip("DYNAMODB_LOCAL is not set")
+	}
+
+	ctx := context.Background()
+	dbc, err := dynamodbstore.NewClient(ctx, dynamodbstore.WithLocal("http://localhost:8000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dynamodbstore.EnsureTables(dbc, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	es := eventstore.NewEventStore(dbc, dbc, core.NewDefaultTimer())
+	id := uuid.New().String()
+	for v := 1; v <= 2; v++ {
+		e, _ := core.NewEvent(id, v, "test", "test", v)
+		if err := es.Publish(e, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	batch := []*core.Event{{Id: id, Version: 3}, {Id: id, Version: 4}, {Id: id, Version: 5}}
+	if err := es.PublishBatch(batch, 2, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []int
+	sub := subscription.NewSubscription(uuid.New().String(), dynamodbstore.NewStreamSource(dbc), dbc)
+	sub.Register(subscription.HandlerFunc(func(e *core.Event, c context.Context) error {
+		if e.Id == id {
+			versions = append(versions, e.Version)
+		}
+		return nil
+	}))
+
+	// the stream also holds the events of other tests, read until ours are delivered
+	for i := 0; i < 100 && len(versions) < 5; i++ {
+		if _, err := sub.Poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, v := range versions {
+		if v != i+1 {
+			t.Fatalf("expected versions 1 to 5 in order, got %v", versions)
+		}
+	}
+	if len(versions) != 5 {
+		t.Errorf("expected 5 events, got %v", versions)
+	}
+}
+
+// TestStreamSourceHead runs against DynamoDB Local, see TestConformance.
+func TestStreamSourceHead(t *testing.T) {
+	if os.Getenv("DYNAMODB_LOCAL") == "" {
+		t.Skip("DYNAMODB_LOCAL is not set")
+	}
+
+	ctx := context.Background()
+	dbc, err := dynamodbstore.NewClient(ctx, dynamodbstore.WithLocal("http://localhost:8000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dynamodbstore.EnsureTables(dbc, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	es := eventstore.NewEventStore(dbc, dbc, core.NewDefaultTimer())
+	id := uuid.New().String()
+	first, _ := core.NewEvent(id, 1, "test", "test", 1)
+	if err := es.Publish(first, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	source := dynamodbstore.NewStreamSource(dbc)
+	position, err := source.Head(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// read once, so that the source keeps the iterators of the open shards
+	if _, position, err = source.Read(position, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	second, _ := core.NewEvent(id, 2, "test", "test", 2)
+	if err := es.Publish(second, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []int
+	for i := 0; i < 100 && len(versions) == 0; i++ {
+		var events []core.Event
+		if events, position, err = source.Read(position, ctx); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			if e.Id == id {
+				versions = append(versions, e.Version)
+			}
+		}
+	}
+
+	if len(versions) != 1 || versions[0] != 2 {
+		t.Errorf("expected only the event committed after the head, got %v", versions)
+	}
+}
@@ -6,17 +6,23 @@ import (
 	"time"
 
 	"github.com/akkgr/eventstore/core"
-	"github.com/akkgr/eventstore/dynamodbstore"
 	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
 )
 
 func BenchmarkStoreLoadEvents(b *testing.B) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	for v := 1; v <= 10; v++ {
+		e, _ := core.NewEvent("123", v, "Customer", "CustomerUpdated", map[string]string{"name": "John Doe"})
+		if err := es.Publish(e, context.Background()); err != nil {
+			panic(err)
+		}
+	}
+
 	b.ReportAllocs()
 	b.ResetTimer()
 
-	dbc := dynamodbstore.NewDynamoDBClient(context.TODO(), true)
-	es := eventstore.NewEventStore(dbc, dbc, core.NewDefaultTimer())
-
 	for i := 0; i < b.N; i++ {
 		_, err := es.LoadEvents("123", 0, context.Background())
 		if err != nil {
@@ -26,12 +32,12 @@ func BenchmarkStoreLoadEvents(b *testing.B) {
 }
 
 func BenchmarkStoreAppendEvents(b *testing.B) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+
 	b.ReportAllocs()
 	b.ResetTimer()
 
-	dbc := dynamodbstore.NewDynamoDBClient(context.TODO(), true)
-	es := eventstore.NewEventStore(dbc, dbc, core.NewDefaultTimer())
-
 	for i := 0; i < b.N; i++ {
 		x := &core.Event{
 			Id:      "123",
@@ -0,0 +1,126 @@
+package eventstore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// AggregateCache keeps the state of up to a fixed number of rehydrated
+// aggregates, evicting the least recently used one when it is full.
+// A Repository that uses the cache restores an aggregate from its entry and
+// applies only the events stored after the cached version, so an entry never
+// needs to be up to date. A cache is meant for the aggregates of a single
+// repository, as its entries are keyed by aggregate id.
+// It is safe for concurrent use.
+type AggregateCache struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	recent   *list.List
+	stats    CacheStats
+}
+
+// CacheStats are the counters of an AggregateCache.
+// Hits and Misses count the lookups of a Repository, Evictions the entries
+// removed to make room for others and Invalidations the entries removed by
+// Invalidate or Handle.
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Size          int
+}
+
+// NewAggregateCache returns a cache of up to capacity aggregates.
+// A capacity of 0 or less caches nothing.
+func NewAggregateCache(capacity int) *AggregateCache {
+	return &AggregateCache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		recent:   list.New(),
+	}
+}
+
+// get returns the cached state of an aggregate.
+func (ac *AggregateCache) get(id string) (core.Snapshot, bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	el, ok := ac.entries[id]
+	if !ok {
+		ac.stats.Misses++
+		return core.Snapshot{}, false
+	}
+	ac.stats.Hits++
+	ac.recent.MoveToFront(el)
+	return el.Value.(core.Snapshot), true
+}
+
+// put caches the state of an aggregate unless the cached state is newer.
+func (ac *AggregateCache) put(s core.Snapshot) {
+	if ac.capacity <= 0 {
+		return
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if el, ok := ac.entries[s.Id]; ok {
+		if el.Value.(core.Snapshot).Version <= s.Version {
+			el.Value = s
+		}
+		ac.recent.MoveToFront(el)
+		return
+	}
+
+	ac.entries[s.Id] = ac.recent.PushFront(s)
+	for ac.recent.Len() > ac.capacity {
+		oldest := ac.recent.Back()
+		ac.recent.Remove(oldest)
+		delete(ac.entries, oldest.Value.(core.Snapshot).Id)
+		ac.stats.Evictions++
+	}
+}
+
+// Invalidate removes the cached state of an aggregate, if any.
+func (ac *AggregateCache) Invalidate(id string) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.remove(id)
+}
+
+func (ac *AggregateCache) remove(id string) {
+	if el, ok := ac.entries[id]; ok {
+		ac.recent.Remove(el)
+		delete(ac.entries, id)
+		ac.stats.Invalidations++
+	}
+}
+
+// Handle removes the cached state of the aggregate of e if it precedes e.
+// It implements subscription.Handler, so that a subscription can drop the
+// aggregates changed by other writers.
+func (ac *AggregateCache) Handle(e *core.Event, c context.Context) error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if el, ok := ac.entries[e.Id]; ok && el.Value.(core.Snapshot).Version < e.Version {
+		ac.remove(e.Id)
+	}
+	return nil
+}
+
+// Stats returns the counters of the cache.
+func (ac *AggregateCache) Stats() CacheStats {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	stats := ac.stats
+	stats.Size = ac.recent.Len()
+	return stats
+}
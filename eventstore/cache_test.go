@@ -0,0 +1,160 @@
+package eventstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+func newCustomer() *customer.Customer {
+	return &customer.Customer{}
+}
+
+// recordingReader records the reads of an event store
+type recordingReader struct {
+	eventstore.EventStoreReader
+	lastEvents int
+	eventsFrom []int
+}
+
+func (r *recordingReader) GetLastEvent(id string, c context.Context) (*core.Event, error) {
+	r.lastEvents++
+	return r.EventStoreReader.GetLastEvent(id, c)
+}
+
+func (r *recordingReader) GetEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	r.eventsFrom = append(r.eventsFrom, v)
+	return r.EventStoreReader.GetEvents(id, v, c)
+}
+
+func TestAggregateCacheLoadsNewerEvents(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	reader := &recordingReader{EventStoreReader: ms}
+	es := eventstore.NewEventStore(reader, ms, core.NewDefaultTimer())
+	cache := eventstore.NewAggregateCache(10)
+	repo := eventstore.NewRepository(es, newCustomer, eventstore.WithCache(cache))
+	ctx := context.Background()
+
+	if err := repo.Save(repo.New(), customerEvents("id", 1, 2), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// another writer appends an event
+	if err := es.PublishBatch(customerEvents("id", 3, 3), 2, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	*reader = recordingReader{EventStoreReader: ms}
+	loaded, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Version != 3 || loaded.Status != "Active" {
+		t.Errorf("unexpected customer %+v", loaded)
+	}
+	if reader.lastEvents != 1 || len(reader.eventsFrom) != 1 || reader.eventsFrom[0] != 2 {
+		t.Errorf("expected the events after the cached version 2 to be read, got %+v", reader)
+	}
+
+	// the refreshed aggregate is cached and up to date, only its last event is read
+	*reader = recordingReader{EventStoreReader: ms}
+	if _, err := repo.Load("id", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reader.lastEvents != 1 || len(reader.eventsFrom) != 0 {
+		t.Errorf("expected only the last event to be read, got %+v", reader)
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 0 || stats.Size != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAggregateCacheEviction(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	store := &recordingStore{EventStore: eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())}
+	cache := eventstore.NewAggregateCache(1)
+	repo := eventstore.NewRepository(store, newCustomer, eventstore.WithCache(cache))
+	ctx := context.Background()
+
+	for _, id := range []string{"a", "b"} {
+		if err := repo.Save(repo.New(), customerEvents(id, 1, 2), ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a was evicted to make room for b
+	if _, err := repo.Load("a", ctx); err != nil {
+		t.Fatal(err)
+	}
+	if store.loadedFrom[0] != 0 {
+		t.Errorf("expected all events to be loaded, got %d", store.loadedFrom[0])
+	}
+
+	stats := cache.Stats()
+	if stats.Misses != 1 || stats.Evictions != 2 || stats.Size != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestAggregateCacheRecreatedStream(t *testing.T) {
+	cache := eventstore.NewAggregateCache(10)
+	ctx := context.Background()
+
+	old := memorystore.NewMemoryStore()
+	repo := eventstore.NewRepository(eventstore.NewEventStore(old, old, core.NewDefaultTimer()), newCustomer, eventstore.WithCache(cache))
+	if err := repo.Save(repo.New(), customerEvents("id", 1, 3), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cached aggregate is ahead of a recreated stream
+	recreated := memorystore.NewMemoryStore()
+	repo = eventstore.NewRepository(eventstore.NewEventStore(recreated, recreated, core.NewDefaultTimer()), newCustomer, eventstore.WithCache(cache))
+	if err := repo.Save(repo.New(), customerEvents("id", 1, 1), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Version != 1 {
+		t.Errorf("expected the recreated stream to be loaded, got version %d", loaded.Version)
+	}
+	if stats := cache.Stats(); stats.Invalidations != 1 {
+		t.Errorf("expected the cached aggregate to be invalidated, got %+v", stats)
+	}
+}
+
+func TestAggregateCacheHandle(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	cache := eventstore.NewAggregateCache(10)
+	repo := eventstore.NewRepository(eventstore.NewEventStore(ms, ms, core.NewDefaultTimer()), newCustomer, eventstore.WithCache(cache))
+	ctx := context.Background()
+
+	events := customerEvents("id", 1, 3)
+	if err := repo.Save(repo.New(), events, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// events the cached aggregate already has keep it
+	if err := cache.Handle(events[2], ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cache.Stats(); stats.Size != 1 || stats.Invalidations != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// newer events remove it
+	if err := cache.Handle(customerEvents("id", 4, 4)[0], ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := cache.Stats(); stats.Size != 0 || stats.Invalidations != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
@@ -2,7 +2,7 @@ package eventstore
 
 import (
 	"context"
-	"sync"
+	"log/slog"
 
 	"github.com/akkgr/eventstore/core"
 )
@@ -11,47 +11,144 @@ type EventPublisher interface {
 	Publish(e *core.Event, c context.Context) error
 }
 
+type BatchPublisher interface {
+	PublishBatch(events []*core.Event, expectedVersion int, c context.Context) error
+}
+
+// EventLoader loads the events of an aggregate.
+// LoadEvents returns, in version order, the events whose version is greater
+// than v: all of them for v = 0 and none for the current version, so that an
+// aggregate restored at version v only needs the events it is missing.
+// It returns core.EventsNotFound if the aggregate has no events and
+// core.InvalidVersion if v is greater than the current version. Events stored
+// with the version of the last event or later, left behind by a publish that
+// failed to replace the last event, are not returned.
 type EventLoader interface {
 	LoadEvents(id string, v int, c context.Context) (*[]core.Event, error)
 }
 
+// NewEventsLoader is implemented by stores that can check whether an aggregate
+// has new events before loading them. LoadNewEvents has the contract of
+// LoadEvents, but it reads the last event first and only reads the earlier
+// events if the last event is after v, which makes it cheaper than LoadEvents
+// for an aggregate that is usually up to date, and slower for one that is not.
+type NewEventsLoader interface {
+	LoadNewEvents(id string, v int, c context.Context) (*[]core.Event, error)
+}
+
+// EventStoreReader reads the events of an aggregate.
+// GetLastEvent returns an empty event if the aggregate has no events.
+// GetEvents returns, in version order, the events stored before the last event
+// whose version is greater than v.
 type EventStoreReader interface {
 	GetLastEvent(id string, c context.Context) (*core.Event, error)
 	GetEvents(id string, v int, c context.Context) (*[]core.Event, error)
 }
 
+// EntityReader is implemented by readers that can read the events of all
+// aggregates of an entity type.
+// ReadByEntity returns the events of entity after position and the position
+// after them. The empty position is the start of the entity's events; no events
+// are returned once all of them have been read. The events of an aggregate are
+// returned in version order.
+type EntityReader interface {
+	ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error)
+}
+
+// StreamLister is implemented by readers that can list the ids of all aggregates.
+// ListStreams returns ids after position and the position after them. The empty
+// position is the start; the returned position is empty once all ids have been
+// listed.
+type StreamLister interface {
+	ListStreams(position string, c context.Context) ([]string, string, error)
+}
+
+// EventStoreWriter writes the events of an aggregate.
+// AppendLastEvent stores the first event and fails if the aggregate already has one.
+// UpdateLastEvent replaces the last event and fails unless its version precedes e.
+// AppendEvent stores an event that is no longer the last one.
+// Writers return a core.ConcurrencyError when a condition on the last event fails.
 type EventStoreWriter interface {
 	AppendLastEvent(e *core.Event, c context.Context) error
 	UpdateLastEvent(e *core.Event, c context.Context) error
 	AppendEvent(e *core.Event, c context.Context) error
 }
 
+// EventStoreTransactWriter is implemented by writers that can move the current
+// last event to the events table and store its successor as a single atomic,
+// conditional operation.
+// last is the event currently stored as the last event of the aggregate.
+// e is the event that replaces it; it is only stored if the last event still
+// has the version of last.
+type EventStoreTransactWriter interface {
+	TransactLastEvent(last *core.Event, e *core.Event, c context.Context) error
+}
+
+// EventStoreBatchWriter is implemented by writers that can append several events
+// of an aggregate as a single atomic, conditional operation.
+// last is the event currently stored as the last event, or nil if the aggregate
+// has no events.
+// events are stored in order and the final one becomes the last event; none of
+// them is stored unless the last event still has the version of last.
+type EventStoreBatchWriter interface {
+	AppendEvents(last *core.Event, events []*core.Event, c context.Context) error
+}
+
 type EventStore struct {
-	reader EventStoreReader
-	writer EventStoreWriter
-	timer  core.Timer
+	reader    EventStoreReader
+	writer    EventStoreWriter
+	timer     core.Timer
+	upcasters *Upcasters
+	logger    *slog.Logger
+}
+
+type Option func(*EventStore)
+
+// WithUpcasters makes the event store stamp published events with the latest
+// schema version of their action and upcast loaded events to it.
+func WithUpcasters(u *Upcasters) Option {
+	return func(es *EventStore) {
+		es.upcasters = u
+	}
 }
 
-func NewEventStore(r EventStoreReader, w EventStoreWriter, t core.Timer) *EventStore {
-	return &EventStore{
+// WithLogger sets the logger of the event store, slog.Default() by default.
+func WithLogger(l *slog.Logger) Option {
+	return func(es *EventStore) {
+		es.logger = l
+	}
+}
+
+func NewEventStore(r EventStoreReader, w EventStoreWriter, t core.Timer, opts ...Option) *EventStore {
+	es := &EventStore{
 		reader: r,
 		writer: w,
 		timer:  t,
+		logger: slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(es)
 	}
+	return es
 }
 
 func (es *EventStore) Publish(e *core.Event, c context.Context) error {
 	e.Created = es.timer.Now()
+	e.FillFromContext(c)
+	es.stampSchemaVersion(e)
 	a, err := es.reader.GetLastEvent(e.Id, c)
 	if err != nil {
 		return err
 	}
 
 	if a.Version != e.Version-1 {
-		return core.InvalidVersion{}
+		return es.conflict(core.ConcurrencyError{StreamId: e.Id, Expected: e.Version - 1, Actual: a.Version}, c)
 	}
 
 	if a.Version > 0 {
+		if tw, ok := es.writer.(EventStoreTransactWriter); ok {
+			return tw.TransactLastEvent(a, e, c)
+		}
 		err = es.writer.AppendEvent(a, c)
 		if err != nil {
 			return err
@@ -64,23 +161,102 @@ func (es *EventStore) Publish(e *core.Event, c context.Context) error {
 	}
 }
 
-// LoadEvents loads all events for an aggregate starting from a specific version.
+// PublishBatch publishes several events of the same aggregate atomically.
+// Either all events are stored or none of them.
+// events must have consecutive versions, starting after expectedVersion.
+// expectedVersion is the version of the aggregate the events were produced from.
+// c is the context.
+func (es *EventStore) PublishBatch(events []*core.Event, expectedVersion int, c context.Context) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	id := events[0].Id
+	for i, e := range events {
+		if e.Id != id {
+			return core.InvalidBatch{}
+		}
+		if e.Version != expectedVersion+i+1 {
+			return core.InvalidVersion{}
+		}
+	}
+
+	if len(events) == 1 {
+		return es.Publish(events[0], c)
+	}
+
+	bw, ok := es.writer.(EventStoreBatchWriter)
+	if !ok {
+		return core.NotSupported{Operation: "batch append"}
+	}
+
+	now := es.timer.Now()
+	for _, e := range events {
+		e.Created = now
+		e.FillFromContext(c)
+		es.stampSchemaVersion(e)
+	}
+
+	a, err := es.reader.GetLastEvent(id, c)
+	if err != nil {
+		return err
+	}
+
+	if a.Version != expectedVersion {
+		return es.conflict(core.ConcurrencyError{StreamId: id, Expected: expectedVersion, Actual: a.Version}, c)
+	}
+
+	if a.Version > 0 {
+		return bw.AppendEvents(a, events, c)
+	}
+	return bw.AppendEvents(nil, events, c)
+}
+
+// ReadByEntity reads the events of all aggregates of an entity type after a position.
+// It returns the events and the position to resume reading from.
+// entity is the entity type.
+// position is the position returned by the previous read, or empty to read from the start.
+// c is the context.
+func (es *EventStore) ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error) {
+	er, ok := es.reader.(EntityReader)
+	if !ok {
+		return nil, position, core.NotSupported{Operation: "read by entity"}
+	}
+
+	events, next, err := er.ReadByEntity(entity, position, c)
+	if err != nil {
+		return nil, position, err
+	}
+	if err := es.upcast(*events); err != nil {
+		return nil, position, err
+	}
+	return events, next, nil
+}
+
+// LoadEvents loads all events for an aggregate after a specific version.
 // If the version is 0, it will return all events.
+// If the version is the current version, it will return no events.
 // If the version is greater than the current version, it will return an error.
+// If c is cancelled or its deadline expires, it returns c.Err() without waiting
+// for the reads.
 // id is the aggregate id.
-// v is the version to start from.
+// v is the version to start after.
 // c is the context.
 func (es *EventStore) LoadEvents(id string, v int, c context.Context) (*[]core.Event, error) {
-	var wg sync.WaitGroup
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+
+	// the reads are cancelled as soon as one of them fails or c is done
+	rc, cancel := context.WithCancel(c)
+	defer cancel()
+
 	eventChan := make(chan *[]core.Event, 1)
 	aggregateChan := make(chan *core.Event, 1)
 	errChan := make(chan error, 2)
 
-	wg.Add(2)
-
 	go func() {
-		defer wg.Done()
-		aggregate, err := es.reader.GetLastEvent(id, c)
+		aggregate, err := es.reader.GetLastEvent(id, rc)
 		if err != nil {
 			errChan <- err
 			return
@@ -89,8 +265,7 @@ func (es *EventStore) LoadEvents(id string, v int, c context.Context) (*[]core.E
 	}()
 
 	go func() {
-		defer wg.Done()
-		events, err := es.reader.GetEvents(id, v, c)
+		events, err := es.reader.GetEvents(id, v, rc)
 		if err != nil {
 			errChan <- err
 			return
@@ -98,37 +273,128 @@ func (es *EventStore) LoadEvents(id string, v int, c context.Context) (*[]core.E
 		eventChan <- events
 	}()
 
-	wg.Wait()
-	close(eventChan)
-	close(aggregateChan)
-	close(errChan)
+	var aggregate *core.Event
+	var events *[]core.Event
+	for received := 0; received < 2; received++ {
+		select {
+		case <-c.Done():
+			return nil, c.Err()
+		case err := <-errChan:
+			if c.Err() != nil {
+				return nil, c.Err()
+			}
+			return nil, err
+		case aggregate = <-aggregateChan:
+		case events = <-eventChan:
+		}
+	}
 
-	if len(errChan) > 0 {
-		return nil, <-errChan
+	if err := checkLastEvent(aggregate, v); err != nil {
+		return nil, err
+	}
+	return es.events(id, v, aggregate, events, c)
+}
+
+// LoadNewEvents loads the events of an aggregate after a specific version, like
+// LoadEvents, but reads the last event first: if its version is v, it returns no
+// events without reading the earlier ones.
+// id is the aggregate id.
+// v is the version to start after.
+// c is the context.
+func (es *EventStore) LoadNewEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	aggregate, err := es.reader.GetLastEvent(id, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkLastEvent(aggregate, v); err != nil {
+		return nil, err
+	}
+	if aggregate.Version == v {
+		return &[]core.Event{}, nil
 	}
 
-	aggregate := <-aggregateChan
-	events := <-eventChan
+	events, err := es.reader.GetEvents(id, v, c)
+	if err != nil {
+		return nil, err
+	}
+	return es.events(id, v, aggregate, events, c)
+}
 
+// checkLastEvent returns the error of loading the events after v of an
+// aggregate whose last event is aggregate.
+func checkLastEvent(aggregate *core.Event, v int) error {
 	if aggregate.Version == 0 {
-		return nil, core.EventsNotFound{}
+		return core.EventsNotFound{}
 	}
-
 	if aggregate.Version < v {
-		return nil, core.InvalidVersion{}
+		return core.InvalidVersion{}
 	}
+	return nil
+}
 
-	all := *events
+// events returns the events after v of an aggregate, given its last event and
+// the events read before it, upcast to the latest schema versions.
+func (es *EventStore) events(id string, v int, aggregate *core.Event, events *[]core.Event, c context.Context) (*[]core.Event, error) {
 	last := *aggregate
 
-	// due to concurrency errors, there might be more events than the last version
-	// these events are ignored and will be overridden in future appends
-	if len(all) > 0 && all[len(all)-1].Version > aggregate.Version {
-		all = all[:aggregate.Version]
-		events = &all
+	// there might be events with the last version or later, which are ignored.
+	// If the first of them is the last event itself, another publish moved it
+	// to the events between the reads, which is expected for a busy stream.
+	// Otherwise they were left behind by a failed append and will be
+	// overridden in future appends.
+	all := []core.Event{}
+	if events != nil {
+		for i, e := range *events {
+			if e.Version < last.Version {
+				all = append(all, e)
+				continue
+			}
+			attrs := []any{"stream_id", id, "version", last.Version,
+				"first_ignored_version", e.Version, "ignored", len(*events) - i}
+			if e.Version == last.Version {
+				es.logger.DebugContext(c, "ignoring events published while loading", attrs...)
+			} else {
+				es.logger.WarnContext(c, "ignoring events stored after the last event", attrs...)
+			}
+			break
+		}
+	}
+
+	if last.Version > v {
+		all = append(all, last)
+	}
+
+	if err := es.upcast(all); err != nil {
+		return nil, err
 	}
 
-	result := append(all, last)
+	return &all, nil
+}
+
+// conflict logs a version conflict detected before writing and returns it.
+func (es *EventStore) conflict(err core.ConcurrencyError, c context.Context) error {
+	es.logger.DebugContext(c, "version conflict",
+		"stream_id", err.StreamId, "expected_version", err.Expected, "actual_version", err.Actual)
+	return err
+}
 
-	return &result, nil
+// stampSchemaVersion sets the schema version of a new event that has none to
+// the latest schema version of its action.
+func (es *EventStore) stampSchemaVersion(e *core.Event) {
+	if es.upcasters != nil && e.SchemaVersion == 0 {
+		e.SchemaVersion = es.upcasters.Latest(e.Action)
+	}
+}
+
+// upcast converts events to the latest schema version of their action.
+func (es *EventStore) upcast(events []core.Event) error {
+	if es.upcasters == nil {
+		return nil
+	}
+	for i := range events {
+		if err := es.upcasters.Upcast(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
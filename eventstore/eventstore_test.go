@@ -1,13 +1,17 @@
 package eventstore_test
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
 	. "github.com/akkgr/eventstore/core"
 	. "github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
 )
 
 // mock Timer
@@ -91,6 +95,27 @@ func (m *mockEventStoreWriter) AppendEvent(e *Event, c context.Context) error {
 	}
 }
 
+// mock EventStoreTransactWriter
+type mockEventStoreTransactWriter struct {
+	mockEventStoreWriter
+	last       *Event
+	transacted *Event
+}
+
+func (m *mockEventStoreTransactWriter) AppendEvent(e *Event, c context.Context) error {
+	return errors.New("unexpected AppendEvent")
+}
+
+func (m *mockEventStoreTransactWriter) UpdateLastEvent(e *Event, c context.Context) error {
+	return errors.New("unexpected UpdateLastEvent")
+}
+
+func (m *mockEventStoreTransactWriter) TransactLastEvent(last *Event, e *Event, c context.Context) error {
+	m.last = last
+	m.transacted = e
+	return nil
+}
+
 func CreateEventStore() *EventStore {
 	return NewEventStore(&mockEventStoreReader{}, &mockEventStoreWriter{}, &mockTimer{})
 }
@@ -134,6 +159,28 @@ func TestSecondEventSuccess(t *testing.T) {
 	}
 }
 
+func TestTransactEventSuccess(t *testing.T) {
+	// create a new event store with a transactional writer
+	w := &mockEventStoreTransactWriter{}
+	es := NewEventStore(&mockEventStoreReader{}, w, &mockTimer{})
+
+	// create a new event
+	event := CreatedEvent("id", 3)
+
+	// append the event
+	err := es.Publish(event, context.Background())
+	if err != nil {
+		t.Error(err)
+	}
+
+	if w.last == nil || w.last.Version != 2 {
+		t.Error("expected the last event to be moved")
+	}
+	if w.transacted != event {
+		t.Error("expected the event to be stored as last event")
+	}
+}
+
 func TestAppendFailure(t *testing.T) {
 	// create a new event store
 	es := CreateEventStore()
@@ -157,11 +204,15 @@ func TestAppendVersionMismatch(t *testing.T) {
 
 	// append the event
 	err := es.Publish(event, context.Background())
-	if err == nil {
-		t.Error("expected an error")
+	var ce ConcurrencyError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected version mismatch, got %v", err)
 	}
-	if condition := err.Error(); condition != "Invalid version" {
-		t.Errorf("expected version mismatch, got %s", condition)
+	if ce != (ConcurrencyError{StreamId: "id", Expected: 3, Actual: 2}) {
+		t.Errorf("unexpected version mismatch %+v", ce)
+	}
+	if !errors.Is(err, InvalidVersion{}) {
+		t.Error("expected version mismatch to be an invalid version")
 	}
 }
 
@@ -190,3 +241,134 @@ func TestLoadEventsFailure(t *testing.T) {
 		t.Error("expected an error")
 	}
 }
+
+func TestLoadEventsOrphans(t *testing.T) {
+	// create an event store that logs to a buffer
+	ms := memorystore.NewMemoryStore()
+	var log bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	es := NewEventStore(ms, ms, &mockTimer{}, WithLogger(logger))
+	ctx := context.Background()
+
+	for _, id := range []string{"orphan", "moved"} {
+		for v := 1; v <= 2; v++ {
+			if err := es.Publish(CreatedEvent(id, v), ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// a writer that failed to update the last event leaves an orphan behind
+	if err := ms.AppendEvent(CreatedEvent("orphan", 3), ctx); err != nil {
+		t.Fatal(err)
+	}
+	// a publish between the reads of a load moves the last event to the
+	// events, as the events table is read after the last event
+	for v := 2; v <= 3; v++ {
+		if err := ms.AppendEvent(CreatedEvent("moved", v), ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"orphan", "moved"} {
+		events, err := es.LoadEvents(id, 0, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*events) != 2 {
+			t.Errorf("expected 2 events of %s, got %d", id, len(*events))
+		}
+	}
+
+	if !strings.Contains(log.String(), `level=WARN msg="ignoring events stored after the last event" stream_id=orphan version=2 first_ignored_version=3 ignored=1`) {
+		t.Errorf("expected a warning for the orphan, got %q", log.String())
+	}
+	if !strings.Contains(log.String(), `level=DEBUG msg="ignoring events published while loading" stream_id=moved version=2 first_ignored_version=2 ignored=2`) {
+		t.Errorf("expected a debug message for the moved event, got %q", log.String())
+	}
+	if strings.Contains(log.String(), "level=WARN msg=\"ignoring events stored after the last event\" stream_id=moved") {
+		t.Errorf("expected no warning for the moved event, got %q", log.String())
+	}
+}
+
+// mock EventStoreReader that blocks until its context is done
+type blockingEventStoreReader struct {
+}
+
+func (m *blockingEventStoreReader) GetLastEvent(id string, c context.Context) (*Event, error) {
+	<-c.Done()
+	return &Event{}, c.Err()
+}
+
+func (m *blockingEventStoreReader) GetEvents(id string, v int, c context.Context) (*[]Event, error) {
+	<-c.Done()
+	return nil, c.Err()
+}
+
+func TestLoadEventsCancelled(t *testing.T) {
+	// create a new event store
+	es := NewEventStore(&blockingEventStoreReader{}, &mockEventStoreWriter{}, &mockTimer{})
+
+	// load events with a deadline
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := es.LoadEvents("id", 0, c)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+
+	// load events with a cancelled context
+	c, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = es.LoadEvents("id", 0, c)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the load to be cancelled, got %v", err)
+	}
+}
+
+func TestPublishBatchNotSupported(t *testing.T) {
+	// create a new event store
+	es := CreateEventStore()
+
+	// publish a batch of events
+	events := []*Event{CreatedEvent("id", 3), CreatedEvent("id", 4)}
+	err := es.PublishBatch(events, 2, context.Background())
+	if !errors.As(err, &NotSupported{}) {
+		t.Errorf("expected not supported, got %v", err)
+	}
+}
+
+func TestPublishBatchVersionGap(t *testing.T) {
+	// create a new event store
+	es := CreateEventStore()
+
+	// publish a batch of events
+	events := []*Event{CreatedEvent("id", 3), CreatedEvent("id", 5)}
+	err := es.PublishBatch(events, 2, context.Background())
+	if !errors.As(err, &InvalidVersion{}) {
+		t.Errorf("expected invalid version, got %v", err)
+	}
+}
+
+func TestPublishBatchMixedIds(t *testing.T) {
+	// create a new event store
+	es := CreateEventStore()
+
+	// publish a batch of events
+	events := []*Event{CreatedEvent("id", 3), CreatedEvent("other", 4)}
+	err := es.PublishBatch(events, 2, context.Background())
+	if !errors.As(err, &InvalidBatch{}) {
+		t.Errorf("expected invalid batch, got %v", err)
+	}
+}
+
+func TestReadByEntityNotSupported(t *testing.T) {
+	// create a new event store
+	es := CreateEventStore()
+
+	// read the events of an entity type
+	_, _, err := es.ReadByEntity("test", "", context.Background())
+	if !errors.As(err, &NotSupported{}) {
+		t.Errorf("expected not supported, got %v", err)
+	}
+}
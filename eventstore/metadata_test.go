@@ -0,0 +1,50 @@
+package eventstore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+func TestPublishFillsMetadataFromContext(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+
+	ctx := core.ContextWithCorrelationId(context.Background(), "request")
+	ctx = core.ContextWithCausationId(ctx, "command")
+	ctx = core.ContextWithActor(ctx, "john")
+	ctx = core.ContextWithMetadata(ctx, "tenant", "acme")
+	ctx = core.ContextWithMetadata(ctx, "source", "context")
+
+	e, _ := core.NewEvent("id", 1, "test", "test", nil, core.WithActor("jane"), core.WithMetadata("source", "event"))
+	if err := es.Publish(e, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := es.LoadEvents("id", 0, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := (*events)[0]
+	if loaded.CorrelationId != "request" || loaded.CausationId != "command" {
+		t.Errorf("expected correlation and causation ids from the context, got %+v", loaded)
+	}
+	if loaded.Actor != "jane" {
+		t.Errorf("expected the actor of the event, got %s", loaded.Actor)
+	}
+	if loaded.Metadata["tenant"] != "acme" || loaded.Metadata["source"] != "event" {
+		t.Errorf("unexpected metadata %v", loaded.Metadata)
+	}
+}
+
+func TestContextWithMetadataDoesNotChangeParent(t *testing.T) {
+	parent := core.ContextWithMetadata(context.Background(), "tenant", "acme")
+	_ = core.ContextWithMetadata(parent, "tenant", "other")
+
+	if v := core.MetadataFromContext(parent)["tenant"]; v != "acme" {
+		t.Errorf("expected the parent metadata to be unchanged, got %s", v)
+	}
+}
@@ -0,0 +1,252 @@
+package eventstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// Store is the part of the event store used by a Repository.
+type Store interface {
+	EventLoader
+	BatchPublisher
+}
+
+type repositoryOptions struct {
+	snapshots SnapshotStore
+	policy    SnapshotPolicy
+	cache     *AggregateCache
+	logger    *slog.Logger
+}
+
+type RepositoryOption func(*repositoryOptions)
+
+// WithSnapshots makes a Repository load aggregates from their latest snapshot
+// and take new snapshots as decided by policy.
+// A snapshot is the JSON encoding of the aggregate, so the aggregate must
+// round-trip through encoding/json: state in unexported fields is lost unless
+// the aggregate implements json.Marshaler and json.Unmarshaler.
+func WithSnapshots(s SnapshotStore, policy SnapshotPolicy) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.snapshots = s
+		o.policy = policy
+	}
+}
+
+// WithCache makes a Repository keep the aggregates it loads and saves in cache,
+// and load a cached aggregate by applying only the events stored after it.
+// If the store is a NewEventsLoader, loading a cached aggregate that is up to
+// date only reads its last event.
+// Like a snapshot, a cached aggregate is kept as its JSON encoding, see
+// WithSnapshots.
+func WithCache(cache *AggregateCache) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.cache = cache
+	}
+}
+
+// WithRepositoryLogger sets the logger of a Repository, slog.Default() by default.
+func WithRepositoryLogger(l *slog.Logger) RepositoryOption {
+	return func(o *repositoryOptions) {
+		o.logger = l
+	}
+}
+
+// Repository loads and saves aggregates of type T through an event store.
+type Repository[T core.Aggregate] struct {
+	store   Store
+	factory func() T
+	repositoryOptions
+}
+
+// NewRepository returns a repository that stores its aggregates in s.
+// factory returns a new, empty aggregate, e.g. func() *customer.Customer { return &customer.Customer{} }.
+// With WithSnapshots or WithCache, aggregates are restored from their JSON
+// encoding, which must keep all of their state.
+func NewRepository[T core.Aggregate](s Store, factory func() T, opts ...RepositoryOption) *Repository[T] {
+	r := &Repository[T]{
+		store:             s,
+		factory:           factory,
+		repositoryOptions: repositoryOptions{logger: slog.Default()},
+	}
+	for _, opt := range opts {
+		opt(&r.repositoryOptions)
+	}
+	return r
+}
+
+// New returns a new, empty aggregate.
+func (r *Repository[T]) New() T {
+	return r.factory()
+}
+
+// Load rehydrates an aggregate by applying its events, starting from its cached
+// state if the repository has a cache, or else from its latest snapshot if the
+// repository has a snapshot store.
+// It returns the first error returned by the aggregate's Apply.
+// id is the aggregate id.
+// c is the context.
+func (r *Repository[T]) Load(id string, c context.Context) (T, error) {
+	var zero T
+
+	a, v, cached := r.loadCached(id, c)
+	if !cached {
+		var err error
+		if a, v, err = r.loadSnapshot(id, c); err != nil {
+			return zero, err
+		}
+	}
+
+	events, err := r.loadEvents(id, v, cached, c)
+	if err != nil && cached {
+		r.cache.Invalidate(id)
+		// the stream is behind the cached aggregate, e.g. because it was
+		// recreated, so the aggregate is rehydrated without the cache
+		if errors.As(err, &core.InvalidVersion{}) {
+			return r.Load(id, c)
+		}
+	}
+	if err != nil {
+		return zero, err
+	}
+
+	for i := range *events {
+		if err := a.Apply(&(*events)[i]); err != nil {
+			return zero, err
+		}
+	}
+
+	r.saveSnapshot(id, a, v, c)
+	r.cacheAggregate(id, a, c)
+	return a, nil
+}
+
+// Save publishes the uncommitted events of an aggregate and applies them to it.
+// The events must continue from the version of the aggregate; nothing is
+// published if another writer has appended events since it was loaded.
+// If an event cannot be applied once the events are published, it returns a
+// core.ApplyError, as the events are committed and must not be saved again.
+// a is the aggregate.
+// events are the uncommitted events.
+// c is the context.
+func (r *Repository[T]) Save(a T, events []*core.Event, c context.Context) error {
+	v := a.GetVersion()
+	if err := r.store.PublishBatch(events, v, c); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		if err := a.Apply(e); err != nil {
+			return core.ApplyError{StreamId: e.Id, Version: e.Version, Err: err}
+		}
+	}
+
+	if len(events) > 0 {
+		r.saveSnapshot(events[0].Id, a, v, c)
+		r.cacheAggregate(events[0].Id, a, c)
+	}
+	return nil
+}
+
+// loadEvents loads the events of an aggregate after version v. A cached
+// aggregate is usually up to date, so if the store can, it checks the last
+// event before reading the others.
+func (r *Repository[T]) loadEvents(id string, v int, cached bool, c context.Context) (*[]core.Event, error) {
+	if nl, ok := r.store.(NewEventsLoader); ok && cached {
+		return nl.LoadNewEvents(id, v, c)
+	}
+	return r.store.LoadEvents(id, v, c)
+}
+
+// loadCached returns the aggregate restored from its cached state, its version
+// and true, or a new aggregate, version 0 and false if it is not cached.
+func (r *Repository[T]) loadCached(id string, c context.Context) (T, int, bool) {
+	a := r.factory()
+	if r.cache == nil {
+		return a, 0, false
+	}
+
+	s, ok := r.cache.get(id)
+	if !ok {
+		return a, 0, false
+	}
+	if err := json.Unmarshal(s.State, a); err != nil || a.GetVersion() != s.Version {
+		r.logger.WarnContext(c, "ignoring cached aggregate that cannot be restored",
+			"stream_id", id, "version", s.Version, "error", err)
+		r.cache.Invalidate(id)
+		return r.factory(), 0, false
+	}
+	return a, s.Version, true
+}
+
+// cacheAggregate stores the state of an aggregate in the cache, if any.
+func (r *Repository[T]) cacheAggregate(id string, a T, c context.Context) {
+	if r.cache == nil {
+		return
+	}
+
+	state, err := json.Marshal(a)
+	if err != nil {
+		r.logger.WarnContext(c, "failed to cache aggregate",
+			"stream_id", id, "version", a.GetVersion(), "error", err)
+		return
+	}
+	r.cache.put(core.Snapshot{Id: id, Version: a.GetVersion(), State: state})
+}
+
+// loadSnapshot returns the aggregate restored from its latest snapshot and the
+// snapshot version, or a new aggregate and version 0 if there is no usable snapshot.
+func (r *Repository[T]) loadSnapshot(id string, c context.Context) (T, int, error) {
+	a := r.factory()
+	if r.snapshots == nil {
+		return a, 0, nil
+	}
+
+	s, err := r.snapshots.GetSnapshot(id, c)
+	if err != nil {
+		return a, 0, err
+	}
+	if s.Version == 0 {
+		return a, 0, nil
+	}
+
+	// a snapshot that no longer matches the aggregate is ignored and the
+	// aggregate is rehydrated from all of its events
+	if err := json.Unmarshal(s.State, a); err != nil {
+		r.logger.WarnContext(c, "ignoring snapshot that cannot be restored",
+			"stream_id", id, "version", s.Version, "error", err)
+		return r.factory(), 0, nil
+	}
+	if a.GetVersion() != s.Version {
+		r.logger.WarnContext(c, "ignoring snapshot whose state has another version",
+			"stream_id", id, "version", s.Version, "state_version", a.GetVersion())
+		return r.factory(), 0, nil
+	}
+	return a, s.Version, nil
+}
+
+// saveSnapshot takes a snapshot of an aggregate whose version moved from v,
+// if the snapshot policy asks for one.
+func (r *Repository[T]) saveSnapshot(id string, a T, v int, c context.Context) {
+	if r.snapshots == nil || !r.policy.ShouldSnapshot(v, a.GetVersion()) {
+		return
+	}
+
+	// the events are already stored, a missing snapshot only makes the next
+	// load replay more events
+	state, err := json.Marshal(a)
+	if err == nil {
+		err = r.snapshots.SaveSnapshot(&core.Snapshot{
+			Id:      id,
+			Version: a.GetVersion(),
+			State:   state,
+		}, c)
+	}
+	if err != nil {
+		r.logger.WarnContext(c, "failed to save snapshot",
+			"stream_id", id, "version", a.GetVersion(), "error", err)
+	}
+}
@@ -0,0 +1,253 @@
+package eventstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+func CreateRepository() *eventstore.Repository[*customer.Customer] {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	return eventstore.NewRepository(es, func() *customer.Customer {
+		return &customer.Customer{}
+	})
+}
+
+func TestRepositorySaveAndLoad(t *testing.T) {
+	repo := CreateRepository()
+	ctx := context.Background()
+
+	// save a new customer
+	c := repo.New()
+	created, _ := core.NewEvent("id", 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John Doe"})
+	updated, _ := core.NewEvent("id", 2, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: "John Doe", Status: "Active"})
+	if err := repo.Save(c, []*core.Event{created, updated}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 || c.Status != "Active" {
+		t.Errorf("expected saved events to be applied, got %+v", c)
+	}
+
+	// load the customer
+	loaded, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != "id" || loaded.Version != 2 || loaded.Name != "John Doe" || loaded.Status != "Active" {
+		t.Errorf("unexpected customer %+v", loaded)
+	}
+}
+
+func TestRepositorySaveStaleAggregate(t *testing.T) {
+	repo := CreateRepository()
+	ctx := context.Background()
+
+	created, _ := core.NewEvent("id", 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John Doe"})
+	if err := repo.Save(repo.New(), []*core.Event{created}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// save against an aggregate that has not seen the first event
+	stale := repo.New()
+	updated, _ := core.NewEvent("id", 1, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: "Jane Doe"})
+	if err := repo.Save(stale, []*core.Event{updated}, ctx); err == nil {
+		t.Error("expected an error")
+	}
+	if stale.Version != 0 {
+		t.Errorf("expected the stale aggregate to be unchanged, got version %d", stale.Version)
+	}
+}
+
+func TestRepositoryLoadApplyError(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	repo := eventstore.NewRepository(es, func() *customer.Customer {
+		return &customer.Customer{}
+	})
+	ctx := context.Background()
+
+	unknown, _ := core.NewEvent("id", 1, "Customer", "CustomerUnknown", nil)
+	if err := es.Publish(unknown, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := repo.Load("id", ctx)
+	if !errors.As(err, &core.InvalidPayload{}) {
+		t.Errorf("expected invalid payload, got %v", err)
+	}
+}
+
+func TestRepositorySaveApplyError(t *testing.T) {
+	repo := CreateRepository()
+	ctx := context.Background()
+
+	unknown, _ := core.NewEvent("id", 1, "Customer", "CustomerUnknown", nil)
+	err := repo.Save(repo.New(), []*core.Event{unknown}, ctx)
+
+	var ae core.ApplyError
+	if !errors.As(err, &ae) {
+		t.Fatalf("expected an apply error, got %v", err)
+	}
+	if ae.StreamId != "id" || ae.Version != 1 || !errors.As(err, &core.InvalidPayload{}) {
+		t.Errorf("unexpected apply error %+v", ae)
+	}
+
+	// the events are committed
+	if err := repo.Save(repo.New(), []*core.Event{unknown}, ctx); !errors.Is(err, core.ConcurrencyError{}) {
+		t.Errorf("expected a concurrency error, got %v", err)
+	}
+}
+
+func TestRepositoryLoadMissing(t *testing.T) {
+	repo := CreateRepository()
+
+	_, err := repo.Load("missing", context.Background())
+	if !errors.As(err, &core.EventsNotFound{}) {
+		t.Errorf("expected events not found, got %v", err)
+	}
+}
+
+// recordingStore records the versions events are loaded from
+type recordingStore struct {
+	*eventstore.EventStore
+	loadedFrom []int
+}
+
+func (s *recordingStore) LoadEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	s.loadedFrom = append(s.loadedFrom, v)
+	return s.EventStore.LoadEvents(id, v, c)
+}
+
+func customerEvents(id string, from int, to int) []*core.Event {
+	var events []*core.Event
+	for v := from; v <= to; v++ {
+		action, payload := customer.CustomerUpdated, any(customer.CustomerUpdatedEvent{Name: "John Doe", Status: "Active"})
+		if v == 1 {
+			action, payload = customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John Doe"}
+		}
+		e, _ := core.NewEvent(id, v, "Customer", action, payload)
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestRepositorySnapshots(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	store := &recordingStore{EventStore: eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())}
+	repo := eventstore.NewRepository(store, func() *customer.Customer {
+		return &customer.Customer{}
+	}, eventstore.WithSnapshots(ms, eventstore.EveryNEvents(3)))
+	ctx := context.Background()
+
+	// saving the first three events takes a snapshot
+	if err := repo.Save(repo.New(), customerEvents("id", 1, 3), ctx); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := ms.GetSnapshot("id", ctx)
+	if s.Version != 3 {
+		t.Fatalf("expected a snapshot at version 3, got %d", s.Version)
+	}
+
+	c, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// saving the next event does not
+	if err := repo.Save(c, customerEvents("id", 4, 4), ctx); err != nil {
+		t.Fatal(err)
+	}
+	s, _ = ms.GetSnapshot("id", ctx)
+	if s.Version != 3 {
+		t.Errorf("expected the snapshot to stay at version 3, got %d", s.Version)
+	}
+
+	// loading replays only the events after the snapshot
+	loaded, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Id != "id" || loaded.Version != 4 || loaded.Status != "Active" {
+		t.Errorf("unexpected customer %+v", loaded)
+	}
+	if last := store.loadedFrom[len(store.loadedFrom)-1]; last != 3 {
+		t.Errorf("expected events to be loaded after version 3, got %d", last)
+	}
+}
+
+func TestRepositoryInvalidSnapshot(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	repo := eventstore.NewRepository(es, func() *customer.Customer {
+		return &customer.Customer{}
+	}, eventstore.WithSnapshots(ms, eventstore.EveryNEvents(10)))
+	ctx := context.Background()
+
+	if err := repo.Save(repo.New(), customerEvents("id", 1, 2), ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.SaveSnapshot(&core.Snapshot{Id: "id", Version: 1, State: []byte("invalid")}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Version != 2 || loaded.Name != "John Doe" {
+		t.Errorf("unexpected customer %+v", loaded)
+	}
+}
+
+type failingSnapshotStore struct {
+	*memorystore.MemoryStore
+}
+
+func (s failingSnapshotStore) SaveSnapshot(snapshot *core.Snapshot, c context.Context) error {
+	return errors.New("some error")
+}
+
+func TestRepositorySnapshotFailure(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	var log bytes.Buffer
+	repo := eventstore.NewRepository(eventstore.NewEventStore(ms, ms, core.NewDefaultTimer()), func() *customer.Customer {
+		return &customer.Customer{}
+	}, eventstore.WithSnapshots(failingSnapshotStore{ms}, eventstore.EveryNEvents(1)),
+		eventstore.WithRepositoryLogger(slog.New(slog.NewTextHandler(&log, nil))))
+
+	// the events are saved even though the snapshot is not
+	if err := repo.Save(repo.New(), customerEvents("id", 1, 1), context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(log.String(), `level=WARN msg="failed to save snapshot" stream_id=id version=1 error="some error"`) {
+		t.Errorf("expected a warning, got %q", log.String())
+	}
+}
+
+func TestEveryNEvents(t *testing.T) {
+	policy := eventstore.EveryNEvents(5)
+	tests := []struct {
+		from, to int
+		expected bool
+	}{
+		{0, 4, false},
+		{0, 5, true},
+		{4, 6, true},
+		{5, 9, false},
+		{9, 12, true},
+	}
+	for _, tt := range tests {
+		if got := policy.ShouldSnapshot(tt.from, tt.to); got != tt.expected {
+			t.Errorf("ShouldSnapshot(%d, %d) = %v, expected %v", tt.from, tt.to, got, tt.expected)
+		}
+	}
+}
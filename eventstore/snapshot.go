@@ -0,0 +1,35 @@
+package eventstore
+
+import (
+	"context"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// SnapshotStore stores the latest snapshot of every aggregate.
+// GetSnapshot returns an empty snapshot if the aggregate has none.
+// SaveSnapshot keeps the stored snapshot if it is newer than s.
+type SnapshotStore interface {
+	GetSnapshot(id string, c context.Context) (*core.Snapshot, error)
+	SaveSnapshot(s *core.Snapshot, c context.Context) error
+}
+
+// SnapshotPolicy decides when a Repository takes a snapshot of an aggregate
+// whose version moved from one version to another, on load or on save.
+type SnapshotPolicy interface {
+	ShouldSnapshot(from int, to int) bool
+}
+
+type everyNEvents struct {
+	n int
+}
+
+// EveryNEvents returns a policy that takes a snapshot whenever the version of
+// an aggregate reaches or passes a multiple of n.
+func EveryNEvents(n int) SnapshotPolicy {
+	return &everyNEvents{n: n}
+}
+
+func (p *everyNEvents) ShouldSnapshot(from int, to int) bool {
+	return p.n > 0 && from/p.n != to/p.n
+}
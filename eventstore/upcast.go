@@ -0,0 +1,67 @@
+package eventstore
+
+import "github.com/akkgr/eventstore/core"
+
+// Upcaster converts the payload of an event from one schema version to the next.
+type Upcaster func(payload []byte) ([]byte, error)
+
+type upcasterKey struct {
+	action  string
+	version int
+}
+
+// Upcasters is a registry of upcasters keyed by action and schema version.
+// Schema versions start at 1; an event without a schema version has version 1.
+// Upcasters are registered before the registry is used and it is safe for
+// concurrent use afterwards.
+type Upcasters struct {
+	upcasters map[upcasterKey]Upcaster
+	latest    map[string]int
+}
+
+func NewUpcasters() *Upcasters {
+	return &Upcasters{
+		upcasters: make(map[upcasterKey]Upcaster),
+		latest:    make(map[string]int),
+	}
+}
+
+// Register adds an upcaster that converts the payloads of action from schema
+// version v to version v+1.
+func (u *Upcasters) Register(action string, v int, up Upcaster) {
+	u.upcasters[upcasterKey{action: action, version: v}] = up
+	if v+1 > u.latest[action] {
+		u.latest[action] = v + 1
+	}
+}
+
+// Latest returns the latest schema version of action.
+func (u *Upcasters) Latest(action string) int {
+	return max(u.latest[action], 1)
+}
+
+// Upcast runs the chain of upcasters of an event's action, from its schema
+// version up to the latest one. It returns a core.MissingUpcaster, and leaves
+// the event unchanged, if the chain has a gap or the event has a schema version
+// later than the latest one.
+func (u *Upcasters) Upcast(e *core.Event) error {
+	latest := u.Latest(e.Action)
+	payload := e.Payload
+	v := max(e.SchemaVersion, 1)
+	for ; v < latest; v++ {
+		up, ok := u.upcasters[upcasterKey{action: e.Action, version: v}]
+		if !ok {
+			break
+		}
+		var err error
+		if payload, err = up(payload); err != nil {
+			return err
+		}
+	}
+	if v != latest {
+		return core.MissingUpcaster{Action: e.Action, Version: v, Latest: latest}
+	}
+	e.Payload = payload
+	e.SchemaVersion = v
+	return nil
+}
@@ -0,0 +1,143 @@
+package eventstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+// legacyCustomerUpdated is the first schema version of customer.CustomerUpdatedEvent
+type legacyCustomerUpdated struct {
+	FullName string
+	Active   bool
+}
+
+// intermediateCustomerUpdated is the second schema version of customer.CustomerUpdatedEvent
+type intermediateCustomerUpdated struct {
+	Name   string
+	Active bool
+}
+
+func customerUpcasters() *eventstore.Upcasters {
+	u := eventstore.NewUpcasters()
+	u.Register(customer.CustomerUpdated, 1, func(payload []byte) ([]byte, error) {
+		var legacy legacyCustomerUpdated
+		if err := json.Unmarshal(payload, &legacy); err != nil {
+			return nil, err
+		}
+		return json.Marshal(intermediateCustomerUpdated{Name: legacy.FullName, Active: legacy.Active})
+	})
+	u.Register(customer.CustomerUpdated, 2, func(payload []byte) ([]byte, error) {
+		var intermediate intermediateCustomerUpdated
+		if err := json.Unmarshal(payload, &intermediate); err != nil {
+			return nil, err
+		}
+		status := "Inactive"
+		if intermediate.Active {
+			status = "Active"
+		}
+		return json.Marshal(customer.CustomerUpdatedEvent{Name: intermediate.Name, Status: status})
+	})
+	return u
+}
+
+func TestUpcastersLatest(t *testing.T) {
+	u := customerUpcasters()
+
+	if v := u.Latest(customer.CustomerUpdated); v != 3 {
+		t.Errorf("expected latest version 3, got %d", v)
+	}
+	if v := u.Latest(customer.CustomerCreated); v != 1 {
+		t.Errorf("expected latest version 1, got %d", v)
+	}
+}
+
+func TestUpcastGap(t *testing.T) {
+	u := eventstore.NewUpcasters()
+	identity := func(payload []byte) ([]byte, error) { return payload, nil }
+	u.Register(customer.CustomerUpdated, 1, identity)
+	u.Register(customer.CustomerUpdated, 3, identity)
+
+	e := &core.Event{Action: customer.CustomerUpdated, SchemaVersion: 1, Payload: []byte("{}")}
+	err := u.Upcast(e)
+	if err != (core.MissingUpcaster{Action: customer.CustomerUpdated, Version: 2, Latest: 4}) {
+		t.Errorf("expected a missing upcaster from version 2, got %v", err)
+	}
+	if e.SchemaVersion != 1 {
+		t.Errorf("expected the event to be left at version 1, got %d", e.SchemaVersion)
+	}
+
+	// an event written with a schema version the registry does not know
+	e = &core.Event{Action: customer.CustomerUpdated, SchemaVersion: 5}
+	if err := u.Upcast(e); err == nil {
+		t.Error("expected an error for a schema version after the latest one")
+	}
+}
+
+func TestLoadEventsUpcastsLegacyEvents(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	ctx := context.Background()
+
+	// events stored before schema versions were introduced
+	legacy := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	created, _ := core.NewEvent("id", 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John"})
+	updated, _ := core.NewEvent("id", 2, "Customer", customer.CustomerUpdated, legacyCustomerUpdated{FullName: "John Doe", Active: true})
+	if err := legacy.PublishBatch([]*core.Event{created, updated}, 0, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer(), eventstore.WithUpcasters(customerUpcasters()))
+	repo := eventstore.NewRepository(es, func() *customer.Customer {
+		return &customer.Customer{}
+	})
+
+	c, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "John Doe" || c.Status != "Active" {
+		t.Errorf("expected the upcasted payload to be applied, got %+v", c)
+	}
+
+	events, err := es.LoadEvents("id", 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := (*events)[1].SchemaVersion; v != 3 {
+		t.Errorf("expected schema version 3, got %d", v)
+	}
+}
+
+func TestPublishStampsLatestSchemaVersion(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer(), eventstore.WithUpcasters(customerUpcasters()))
+	ctx := context.Background()
+
+	updated, _ := core.NewEvent("id", 1, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: "John Doe", Status: "Active"})
+	if err := es.Publish(updated, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	last, _ := ms.GetLastEvent("id", ctx)
+	if last.SchemaVersion != 3 {
+		t.Errorf("expected schema version 3, got %d", last.SchemaVersion)
+	}
+
+	// events of the latest schema version are not upcasted again
+	events, err := es.LoadEvents("id", 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload customer.CustomerUpdatedEvent
+	if err := json.Unmarshal((*events)[0].Payload, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Name != "John Doe" || payload.Status != "Active" {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+}
@@ -0,0 +1,703 @@
+// Package eventstoretest provides a conformance suite for event store backends.
+//
+// A backend runs the suite from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		eventstoretest.Run(t, func(t *testing.T) eventstoretest.Backend {
+//			return memorystore.NewMemoryStore()
+//		})
+//	}
+package eventstoretest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/google/uuid"
+)
+
+// Backend is an event store backend under test.
+type Backend interface {
+	eventstore.EventStoreReader
+	eventstore.EventStoreWriter
+}
+
+// Factory returns the backend used by a single test.
+// Every test uses its own aggregate ids, so a factory may return a backend
+// that is shared between tests.
+type Factory func(t *testing.T) Backend
+
+// concurrentPublishers is the number of publishers racing on the same version.
+const concurrentPublishers = 10
+
+const (
+	// entityReadTimeout is the time to wait for the events read by entity.
+	entityReadTimeout = 10 * time.Second
+	// entityReadInterval is the time between reads by entity that return no events.
+	entityReadInterval = 50 * time.Millisecond
+)
+
+// Run runs the conformance suite against the backends returned by factory.
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, b Backend)
+	}{
+		{"MissingStream", testMissingStream},
+		{"PublishAndLoad", testPublishAndLoad},
+		{"GetEventsOrder", testGetEventsOrder},
+		{"GetEventsOffset", testGetEventsOffset},
+		{"LoadEventsOffset", testLoadEventsOffset},
+		{"VersionGap", testVersionGap},
+		{"DuplicateVersion", testDuplicateVersion},
+		{"StreamsAreIsolated", testStreamsAreIsolated},
+		{"ConcurrentFirstPublish", testConcurrentFirstPublish},
+		{"ConcurrentPublish", testConcurrentPublish},
+		{"BatchOnNewStream", batch(testBatchOnNewStream)},
+		{"BatchAppend", batch(testBatchAppend)},
+		{"BatchConflict", batch(testBatchConflict)},
+		{"ConcurrentBatch", batch(testConcurrentBatch)},
+		{"Snapshots", testSnapshots},
+		{"ReadByEntity", testReadByEntity},
+		{"ReadByEntityCommitOrder", testReadByEntityCommitOrder},
+		{"Metadata", testMetadata},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, factory(t))
+		})
+	}
+}
+
+// batch skips a test unless the backend supports atomic batch appends.
+func batch(test func(t *testing.T, b Backend)) func(t *testing.T, b Backend) {
+	return func(t *testing.T, b Backend) {
+		if _, ok := b.(eventstore.EventStoreBatchWriter); !ok {
+			t.Skip("backend does not support batch appends")
+		}
+		test(t, b)
+	}
+}
+
+func newEventStore(b Backend) *eventstore.EventStore {
+	return eventstore.NewEventStore(b, b, core.NewDefaultTimer())
+}
+
+func newId() string {
+	return uuid.New().String()
+}
+
+func newEvent(t *testing.T, id string, version int) *core.Event {
+	t.Helper()
+	e, err := core.NewEvent(id, version, "Test", "TestEvent", map[string]any{
+		"version": version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// publish publishes the versions 1 to n of the aggregate id.
+func publish(t *testing.T, es *eventstore.EventStore, id string, n int) {
+	t.Helper()
+	for v := 1; v <= n; v++ {
+		if err := es.Publish(newEvent(t, id, v), context.Background()); err != nil {
+			t.Fatalf("publish version %d: %v", v, err)
+		}
+	}
+}
+
+// newBatch returns the versions from to to of the aggregate id.
+func newBatch(t *testing.T, id string, from int, to int) []*core.Event {
+	t.Helper()
+	var events []*core.Event
+	for v := from; v <= to; v++ {
+		events = append(events, newEvent(t, id, v))
+	}
+	return events
+}
+
+// expectConcurrencyError fails the test unless err is a core.ConcurrencyError
+// for the stream id at the expected version.
+func expectConcurrencyError(t *testing.T, err error, id string, expected int) core.ConcurrencyError {
+	t.Helper()
+	var ce core.ConcurrencyError
+	if !errors.As(err, &ce) {
+		t.Errorf("expected a concurrency error, got %v", err)
+		return ce
+	}
+	if ce.StreamId != id || ce.Expected != expected {
+		t.Errorf("expected a concurrency error on %s at version %d, got %+v", id, expected, ce)
+	}
+	return ce
+}
+
+// versions returns the versions of events.
+func versions(events *[]core.Event) []int {
+	result := []int{}
+	if events == nil {
+		return result
+	}
+	for _, e := range *events {
+		result = append(result, e.Version)
+	}
+	return result
+}
+
+// expectVersions fails the test unless events have exactly the versions from to to.
+func expectVersions(t *testing.T, events *[]core.Event, from int, to int) {
+	t.Helper()
+	expected := []int{}
+	for v := from; v <= to; v++ {
+		expected = append(expected, v)
+	}
+	if got := versions(events); fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected versions %v, got %v", expected, got)
+	}
+}
+
+func testMissingStream(t *testing.T, b Backend) {
+	ctx := context.Background()
+	id := newId()
+
+	last, err := b.GetLastEvent(id, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last.Version != 0 {
+		t.Errorf("expected version 0, got %d", last.Version)
+	}
+
+	events, err := b.GetEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions(events)) != 0 {
+		t.Errorf("expected no events, got %v", versions(events))
+	}
+
+	_, err = newEventStore(b).LoadEvents(id, 0, ctx)
+	if !errors.As(err, &core.EventsNotFound{}) {
+		t.Errorf("expected EventsNotFound, got %v", err)
+	}
+}
+
+func testPublishAndLoad(t *testing.T, b Backend) {
+	es := newEventStore(b)
+	id := newId()
+	publish(t, es, id, 5)
+
+	events, err := es.LoadEvents(id, 0, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 5)
+
+	for _, e := range *events {
+		expected := newEvent(t, id, e.Version)
+		if e.Id != id || e.Entity != expected.Entity || e.Action != expected.Action {
+			t.Errorf("unexpected event %+v", e)
+		}
+		if string(e.Payload) != string(expected.Payload) {
+			t.Errorf("expected payload %s, got %s", expected.Payload, e.Payload)
+		}
+		if e.Created.IsZero() {
+			t.Errorf("expected created to be set on version %d", e.Version)
+		}
+	}
+}
+
+func testGetEventsOrder(t *testing.T, b Backend) {
+	id := newId()
+	publish(t, newEventStore(b), id, 12)
+
+	events, err := b.GetEvents(id, 0, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 11)
+}
+
+func testGetEventsOffset(t *testing.T, b Backend) {
+	ctx := context.Background()
+	id := newId()
+	publish(t, newEventStore(b), id, 5)
+
+	events, err := b.GetEvents(id, 2, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 3, 4)
+
+	events, err = b.GetEvents(id, 4, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 5, 4)
+}
+
+func testLoadEventsOffset(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+	publish(t, es, id, 5)
+
+	events, err := es.LoadEvents(id, 2, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 3, 5)
+
+	events, err = es.LoadEvents(id, 5, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 6, 5)
+
+	_, err = es.LoadEvents(id, 6, ctx)
+	if !errors.As(err, &core.InvalidVersion{}) {
+		t.Errorf("expected InvalidVersion, got %v", err)
+	}
+}
+
+func testVersionGap(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+
+	err := es.Publish(newEvent(t, id, 2), ctx)
+	if ce := expectConcurrencyError(t, err, id, 1); ce.Actual != 0 {
+		t.Errorf("expected actual version 0, got %d", ce.Actual)
+	}
+
+	publish(t, es, id, 1)
+
+	err = es.Publish(newEvent(t, id, 3), ctx)
+	if ce := expectConcurrencyError(t, err, id, 2); ce.Actual != 1 {
+		t.Errorf("expected actual version 1, got %d", ce.Actual)
+	}
+
+	events, err := es.LoadEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 1)
+}
+
+func testDuplicateVersion(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+	publish(t, es, id, 2)
+
+	for v := 1; v <= 2; v++ {
+		err := es.Publish(newEvent(t, id, v), ctx)
+		if ce := expectConcurrencyError(t, err, id, v-1); ce.Actual != 2 {
+			t.Errorf("expected actual version 2, got %d", ce.Actual)
+		}
+	}
+
+	expectConcurrencyError(t, b.AppendLastEvent(newEvent(t, id, 1), ctx), id, 0)
+	expectConcurrencyError(t, b.UpdateLastEvent(newEvent(t, id, 4), ctx), id, 3)
+
+	events, err := es.LoadEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 2)
+}
+
+func testStreamsAreIsolated(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	first, second := newId(), newId()
+	publish(t, es, first, 3)
+	publish(t, es, second, 2)
+
+	events, err := es.LoadEvents(first, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 3)
+	for _, e := range *events {
+		if e.Id != first {
+			t.Errorf("expected id %s, got %s", first, e.Id)
+		}
+	}
+
+	events, err = es.LoadEvents(second, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 2)
+}
+
+// race publishes version v of the aggregate id from concurrent publishers and
+// returns the number of successful publishes.
+func race(t *testing.T, es *eventstore.EventStore, id string, v int) int {
+	t.Helper()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+
+	events := make([]*core.Event, concurrentPublishers)
+	for i := range events {
+		events[i] = newEvent(t, id, v)
+	}
+
+	for _, e := range events {
+		wg.Add(1)
+		go func(e *core.Event) {
+			defer wg.Done()
+			err := es.Publish(e, context.Background())
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			} else {
+				expectConcurrencyError(t, err, id, v-1)
+			}
+		}(e)
+	}
+	wg.Wait()
+
+	return succeeded
+}
+
+func testConcurrentFirstPublish(t *testing.T, b Backend) {
+	es := newEventStore(b)
+	id := newId()
+
+	if n := race(t, es, id, 1); n != 1 {
+		t.Errorf("expected exactly one publisher to succeed, got %d", n)
+	}
+
+	events, err := es.LoadEvents(id, 0, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 1)
+}
+
+func testConcurrentPublish(t *testing.T, b Backend) {
+	es := newEventStore(b)
+	id := newId()
+	publish(t, es, id, 2)
+
+	if n := race(t, es, id, 3); n != 1 {
+		t.Errorf("expected exactly one publisher to succeed, got %d", n)
+	}
+
+	if err := es.Publish(newEvent(t, id, 4), context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := es.LoadEvents(id, 0, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 4)
+}
+
+func testBatchOnNewStream(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+
+	if err := es.PublishBatch(newBatch(t, id, 1, 3), 0, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := es.LoadEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 3)
+}
+
+func testBatchAppend(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+	publish(t, es, id, 2)
+
+	if err := es.PublishBatch(newBatch(t, id, 3, 5), 2, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := es.Publish(newEvent(t, id, 6), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := es.LoadEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 6)
+}
+
+func testBatchConflict(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+	publish(t, es, id, 3)
+
+	err := es.PublishBatch(newBatch(t, id, 3, 5), 2, ctx)
+	if ce := expectConcurrencyError(t, err, id, 2); ce.Actual != 3 {
+		t.Errorf("expected actual version 3, got %d", ce.Actual)
+	}
+	err = es.PublishBatch(newBatch(t, id, 1, 2), 0, ctx)
+	if ce := expectConcurrencyError(t, err, id, 0); ce.Actual != 3 {
+		t.Errorf("expected actual version 3, got %d", ce.Actual)
+	}
+	bw := b.(eventstore.EventStoreBatchWriter)
+	expectConcurrencyError(t, bw.AppendEvents(nil, newBatch(t, id, 1, 2), ctx), id, 0)
+	expectConcurrencyError(t, bw.AppendEvents(newEvent(t, id, 2), newBatch(t, id, 3, 4), ctx), id, 2)
+
+	events, err := es.LoadEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 3)
+}
+
+func testConcurrentBatch(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+	publish(t, es, id, 1)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+
+	batches := make([][]*core.Event, concurrentPublishers)
+	for i := range batches {
+		batches[i] = newBatch(t, id, 2, 4)
+	}
+
+	for _, events := range batches {
+		wg.Add(1)
+		go func(events []*core.Event) {
+			defer wg.Done()
+			err := es.PublishBatch(events, 1, ctx)
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				succeeded++
+			} else {
+				expectConcurrencyError(t, err, id, 1)
+			}
+		}(events)
+	}
+	wg.Wait()
+
+	if succeeded != 1 {
+		t.Errorf("expected exactly one publisher to succeed, got %d", succeeded)
+	}
+
+	events, err := es.LoadEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 4)
+}
+
+func testSnapshots(t *testing.T, b Backend) {
+	ss, ok := b.(eventstore.SnapshotStore)
+	if !ok {
+		t.Skip("backend does not store snapshots")
+	}
+	ctx := context.Background()
+	id := newId()
+
+	s, err := ss.GetSnapshot(id, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 0 {
+		t.Errorf("expected no snapshot, got version %d", s.Version)
+	}
+
+	for _, v := range []int{5, 10, 7} {
+		state := []byte(fmt.Sprintf(`{"version":%d}`, v))
+		if err := ss.SaveSnapshot(&core.Snapshot{Id: id, Version: v, State: state}, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err = ss.GetSnapshot(id, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Id != id || s.Version != 10 || string(s.State) != `{"version":10}` {
+		t.Errorf("expected the latest snapshot, got %+v", s)
+	}
+}
+
+// readByEntity reads the events of entity after position until n events are
+// read. Backends may hold back recent events, so it waits for them up to
+// entityReadTimeout.
+func readByEntity(t *testing.T, er eventstore.EntityReader, entity string, position string, n int) ([]core.Event, string) {
+	t.Helper()
+	var all []core.Event
+	deadline := time.Now().Add(entityReadTimeout)
+	for len(all) < n {
+		events, next, err := er.ReadByEntity(entity, position, context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(*events) == 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d events, read %d", n, len(all))
+			}
+			time.Sleep(entityReadInterval)
+		}
+		all = append(all, *events...)
+		position = next
+	}
+	return all, position
+}
+
+// entityVersions returns the versions of events by aggregate id.
+func entityVersions(t *testing.T, events []core.Event, entity string) map[string][]int {
+	t.Helper()
+	versions := map[string][]int{}
+	for _, e := range events {
+		if e.Entity != entity {
+			t.Errorf("expected entity %s, got %s", entity, e.Entity)
+		}
+		versions[e.Id] = append(versions[e.Id], e.Version)
+	}
+	return versions
+}
+
+func testReadByEntity(t *testing.T, b Backend) {
+	er, ok := b.(eventstore.EntityReader)
+	if !ok {
+		t.Skip("backend does not read by entity")
+	}
+	ctx := context.Background()
+	es := newEventStore(b)
+	entity := "Test" + newId()
+	first, second, other := newId(), newId(), newId()
+
+	for _, e := range []*core.Event{
+		{Id: first, Version: 1, Entity: entity},
+		{Id: second, Version: 1, Entity: entity},
+		{Id: other, Version: 1, Entity: "Other" + entity},
+		{Id: first, Version: 2, Entity: entity},
+		{Id: second, Version: 2, Entity: entity},
+		{Id: first, Version: 3, Entity: entity},
+	} {
+		if err := es.Publish(e, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, position := readByEntity(t, er, entity, "", 5)
+	versions := entityVersions(t, events, entity)
+	if fmt.Sprint(versions[first]) != "[1 2 3]" || fmt.Sprint(versions[second]) != "[1 2]" || len(versions) != 2 {
+		t.Errorf("unexpected versions %v", versions)
+	}
+
+	// reading resumes after the position of the last read
+	if err := es.Publish(&core.Event{Id: second, Version: 3, Entity: entity}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	events, _ = readByEntity(t, er, entity, position, 1)
+	versions = entityVersions(t, events, entity)
+	if fmt.Sprint(versions[second]) != "[3]" || len(versions) != 1 {
+		t.Errorf("unexpected versions after resuming %v", versions)
+	}
+}
+
+// fixedTimer stamps events with a fixed creation time.
+type fixedTimer struct {
+	now time.Time
+}
+
+func (t fixedTimer) Now() time.Time {
+	return t.now
+}
+
+// testReadByEntityCommitOrder publishes an event that is created before
+// another one but committed after it, while a reader reads between both
+// commits: resuming the reader must not skip the event committed last.
+func testReadByEntityCommitOrder(t *testing.T, b Backend) {
+	er, ok := b.(eventstore.EntityReader)
+	if !ok {
+		t.Skip("backend does not read by entity")
+	}
+	ctx := context.Background()
+	entity := "Test" + newId()
+	created := time.Now()
+	early := eventstore.NewEventStore(b, b, fixedTimer{created})
+	late := eventstore.NewEventStore(b, b, fixedTimer{created.Add(time.Millisecond)})
+	first, second := newId(), newId()
+
+	if err := late.Publish(&core.Event{Id: second, Version: 1, Entity: entity}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	events, position, err := er.ReadByEntity(entity, "", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := *events
+
+	if err := early.Publish(&core.Event{Id: first, Version: 1, Entity: entity}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := readByEntity(t, er, entity, position, 2-len(read))
+	read = append(read, rest...)
+
+	versions := entityVersions(t, read, entity)
+	if fmt.Sprint(versions[first]) != "[1]" || fmt.Sprint(versions[second]) != "[1]" || len(versions) != 2 {
+		t.Errorf("unexpected versions %v", versions)
+	}
+}
+
+func testMetadata(t *testing.T, b Backend) {
+	ctx := context.Background()
+	es := newEventStore(b)
+	id := newId()
+
+	for v := 1; v <= 2; v++ {
+		e, err := core.NewEvent(id, v, "Test", "TestEvent", nil,
+			core.WithCorrelationId("correlation"),
+			core.WithCausationId(fmt.Sprintf("cause-%d", v)),
+			core.WithActor("actor"),
+			core.WithMetadata("tenant", "acme"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := es.Publish(e, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := es.Publish(newEvent(t, id, 3), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := es.LoadEvents(id, 0, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions(t, events, 1, 3)
+	for _, e := range (*events)[:2] {
+		if e.CorrelationId != "correlation" || e.CausationId != fmt.Sprintf("cause-%d", e.Version) ||
+			e.Actor != "actor" || e.Metadata["tenant"] != "acme" || len(e.Metadata) != 1 {
+			t.Errorf("unexpected metadata of version %d: %+v", e.Version, e)
+		}
+	}
+	if e := (*events)[2]; e.CorrelationId != "" || e.CausationId != "" || e.Actor != "" || len(e.Metadata) != 0 {
+		t.Errorf("expected no metadata on version 3, got %+v", e)
+	}
+}
@@ -0,0 +1,19 @@
+package filestore_test
+
+import (
+	"testing"
+
+	"github.com/akkgr/eventstore/eventstoretest"
+	"github.com/akkgr/eventstore/filestore"
+)
+
+func TestConformance(t *testing.T) {
+	eventstoretest.Run(t, func(t *testing.T) eventstoretest.Backend {
+		fs, err := filestore.Open(t.TempDir(), filestore.WithSyncPolicy(filestore.SyncNever))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { fs.Close() })
+		return fs
+	})
+}
@@ -0,0 +1,477 @@
+// Package filestore stores events in append-only log files on the local disk.
+//
+// Events are written to numbered segment files as checksummed records; a new
+// segment is started once the current one reaches the segment size. Each
+// stream keeps an index of where its events are stored. A record that was only
+// partially written when the process crashed is discarded on open.
+//
+// The index is held in memory only and rebuilt by decoding every record when
+// the store is opened, so opening takes time proportional to the size of the
+// store. This is deliberate: the segments are the only state that has to
+// survive a crash, and a persisted index would need its own recovery, since it
+// could be behind or ahead of the segments after one. The file store is meant
+// for development and for small single-node deployments, where reading the
+// segments once on startup is cheap; larger stores should use another backend.
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// DefaultSegmentSize is the size after which a new segment is started.
+const DefaultSegmentSize = 64 << 20
+
+// readLimit is the maximum number of events returned by a single Read or ReadByEntity.
+const readLimit = 100
+
+// SyncPolicy decides when appended records are flushed to stable storage.
+type SyncPolicy struct {
+	interval time.Duration
+	never    bool
+}
+
+var (
+	// SyncAlways flushes every append before it returns. It is the default.
+	SyncAlways = SyncPolicy{}
+	// SyncNever leaves flushing to the operating system, and to Close.
+	// Appends survive a crash of the process, but not of the machine.
+	SyncNever = SyncPolicy{never: true}
+)
+
+// SyncEvery flushes the appends every d, in the background, and also flushes
+// an append if the last flush happened more than d ago. The appends of the last
+// d may be lost if the machine crashes.
+func SyncEvery(d time.Duration) SyncPolicy {
+	return SyncPolicy{interval: d}
+}
+
+// location is where an event is stored: the record that holds it and its index
+// in the record.
+type location struct {
+	segment *segment
+	offset  int64
+	index   int
+	entity  string
+}
+
+// FileStore is an implementation of the event store reader and writer
+// interfaces on top of append-only files. It is safe for concurrent use, but a
+// directory must not be opened by more than one store at a time.
+type FileStore struct {
+	mu          sync.RWMutex
+	dir         string
+	segmentSize int64
+	sync        SyncPolicy
+	lastSync    time.Time
+	dirty       bool
+	stop        chan struct{}
+	stopped     chan struct{}
+	segments    []*segment
+	log         []location
+	streams     map[string][]int
+}
+
+type Option func(*FileStore)
+
+// WithSyncPolicy sets when appends are flushed to stable storage.
+func WithSyncPolicy(p SyncPolicy) Option {
+	return func(fs *FileStore) {
+		fs.sync = p
+	}
+}
+
+// WithSegmentSize sets the size after which a new segment is started.
+func WithSegmentSize(size int64) Option {
+	return func(fs *FileStore) {
+		fs.segmentSize = size
+	}
+}
+
+// Open opens the store in dir, creating the directory if needed.
+// A torn record at the end of the last segment is truncated.
+func Open(dir string, opts ...Option) (*FileStore, error) {
+	fs := &FileStore{
+		dir:         dir,
+		segmentSize: DefaultSegmentSize,
+		sync:        SyncAlways,
+		streams:     make(map[string][]int),
+	}
+	for _, opt := range opts {
+		opt(fs)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	numbers, err := segmentNumbers(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, number := range numbers {
+		s, err := openSegment(dir, number)
+		if err != nil {
+			fs.Close()
+			return nil, err
+		}
+		fs.segments = append(fs.segments, s)
+		if err := fs.recover(s, i == len(numbers)-1); err != nil {
+			fs.Close()
+			return nil, err
+		}
+	}
+
+	if len(fs.segments) == 0 {
+		if err := fs.roll(); err != nil {
+			return nil, err
+		}
+	}
+
+	if fs.sync.interval > 0 {
+		fs.stop, fs.stopped = make(chan struct{}), make(chan struct{})
+		go fs.syncLoop()
+	}
+	return fs, nil
+}
+
+// syncLoop flushes the appends left unflushed by SyncEvery, until Close.
+func (fs *FileStore) syncLoop() {
+	defer close(fs.stopped)
+	ticker := time.NewTicker(fs.sync.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fs.stop:
+			return
+		case <-ticker.C:
+			fs.mu.Lock()
+			if fs.dirty {
+				// an error is reported by the next append that flushes
+				fs.flush()
+			}
+			fs.mu.Unlock()
+		}
+	}
+}
+
+// recover indexes the records of s. A torn record is truncated if s is the
+// last segment, since only the last record written can be torn.
+func (fs *FileStore) recover(s *segment, last bool) error {
+	var offset int64
+	for offset < s.size {
+		events, next, err := s.read(offset)
+		if errors.Is(err, errTornRecord) && last {
+			s.size = offset
+			return s.file.Truncate(offset)
+		}
+		if err != nil {
+			return fmt.Errorf("segment %s at offset %d: %w", segmentName(s.number), offset, err)
+		}
+		fs.index(s, offset, events)
+		offset = next
+	}
+	return nil
+}
+
+func (fs *FileStore) index(s *segment, offset int64, events []core.Event) {
+	for i, e := range events {
+		fs.streams[e.Id] = append(fs.streams[e.Id], len(fs.log))
+		fs.log = append(fs.log, location{segment: s, offset: offset, index: i, entity: e.Entity})
+	}
+}
+
+// active returns the segment appends are written to.
+func (fs *FileStore) active() *segment {
+	return fs.segments[len(fs.segments)-1]
+}
+
+// roll starts a new segment.
+func (fs *FileStore) roll() error {
+	number := 1
+	if len(fs.segments) > 0 {
+		if err := fs.flush(); err != nil {
+			return err
+		}
+		number = fs.active().number + 1
+	}
+
+	s, err := openSegment(fs.dir, number)
+	if err != nil {
+		return err
+	}
+	fs.segments = append(fs.segments, s)
+
+	if fs.sync.never {
+		return nil
+	}
+	return syncDir(fs.dir)
+}
+
+// flush flushes the active segment unless the sync policy is SyncNever.
+func (fs *FileStore) flush() error {
+	if fs.sync.never {
+		return nil
+	}
+	fs.lastSync = time.Now()
+	if err := fs.active().file.Sync(); err != nil {
+		return err
+	}
+	fs.dirty = false
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Sync flushes all appends to stable storage, whatever the sync policy.
+func (fs *FileStore) Sync() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.active().file.Sync(); err != nil {
+		return err
+	}
+	fs.dirty = false
+	return nil
+}
+
+// Close flushes all appends to stable storage and closes the segments.
+func (fs *FileStore) Close() error {
+	if fs.stop != nil {
+		close(fs.stop)
+		<-fs.stopped
+		fs.stop = nil
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	var errs []error
+	for i, s := range fs.segments {
+		if i == len(fs.segments)-1 {
+			errs = append(errs, s.file.Sync())
+		}
+		errs = append(errs, s.file.Close())
+	}
+	fs.segments = nil
+	return errors.Join(errs...)
+}
+
+// AppendLastEvent stores the first event of the aggregate, but only if the
+// aggregate has no events.
+func (fs *FileStore) AppendLastEvent(e *core.Event, c context.Context) error {
+	return fs.append(0, []*core.Event{e})
+}
+
+// UpdateLastEvent stores e, but only if the last event of the aggregate has the
+// version preceding e.
+func (fs *FileStore) UpdateLastEvent(e *core.Event, c context.Context) error {
+	return fs.append(e.Version-1, []*core.Event{e})
+}
+
+// AppendEvent does nothing: the last event of an aggregate stays in the log
+// when it is followed by another event.
+func (fs *FileStore) AppendEvent(e *core.Event, c context.Context) error {
+	return nil
+}
+
+// TransactLastEvent stores e, but only if the last event of the aggregate still
+// has the version of last.
+func (fs *FileStore) TransactLastEvent(last *core.Event, e *core.Event, c context.Context) error {
+	return fs.append(last.Version, []*core.Event{e})
+}
+
+// AppendEvents stores events as a single record, but only if the last event of
+// the aggregate still has the version of last, or if it has no events when last
+// is nil.
+func (fs *FileStore) AppendEvents(last *core.Event, events []*core.Event, c context.Context) error {
+	expected := 0
+	if last != nil {
+		expected = last.Version
+	}
+	return fs.append(expected, events)
+}
+
+// append writes events as a single record if the aggregate is at the expected
+// version. The events must follow it: the index of a stream maps version n to
+// its n-th event, so an event with another version would corrupt it.
+func (fs *FileStore) append(expected int, events []*core.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	id := events[0].Id
+	for i, e := range events {
+		if e.Id != id {
+			return core.InvalidBatch{}
+		}
+		if e.Version != expected+i+1 {
+			return core.InvalidVersion{}
+		}
+	}
+
+	batch := make([]core.Event, len(events))
+	for i, e := range events {
+		batch[i] = *e
+	}
+	record, err := encodeRecord(batch)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if actual := len(fs.streams[id]); actual != expected {
+		return core.ConcurrencyError{StreamId: id, Expected: expected, Actual: actual}
+	}
+
+	if s := fs.active(); s.size > 0 && s.size+int64(len(record)) > fs.segmentSize {
+		if err := fs.roll(); err != nil {
+			return err
+		}
+	}
+
+	s := fs.active()
+	offset, err := s.append(record)
+	if err != nil {
+		return err
+	}
+	fs.dirty = true
+	if !fs.sync.never && time.Since(fs.lastSync) >= fs.sync.interval {
+		if err := fs.flush(); err != nil {
+			s.file.Truncate(offset)
+			s.size = offset
+			return err
+		}
+	}
+
+	fs.index(s, offset, batch)
+	return nil
+}
+
+// reader reads the events of the locations, decoding every record once.
+type reader struct {
+	record  []core.Event
+	segment *segment
+	offset  int64
+}
+
+func (r *reader) read(l location) (core.Event, error) {
+	if r.record == nil || r.segment != l.segment || r.offset != l.offset {
+		events, _, err := l.segment.read(l.offset)
+		if err != nil {
+			return core.Event{}, err
+		}
+		r.record, r.segment, r.offset = events, l.segment, l.offset
+	}
+	return r.record[l.index], nil
+}
+
+func (fs *FileStore) GetLastEvent(id string, c context.Context) (*core.Event, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	stream := fs.streams[id]
+	if len(stream) == 0 {
+		return &core.Event{}, nil
+	}
+	r := reader{}
+	e, err := r.read(fs.log[stream[len(stream)-1]])
+	if err != nil {
+		return &core.Event{}, err
+	}
+	return &e, nil
+}
+
+func (fs *FileStore) GetEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	// the event of version n is at index n-1 of the stream
+	stream := fs.streams[id]
+	events := []core.Event{}
+	r := reader{}
+	for i := max(v, 0); i < len(stream)-1; i++ {
+		e, err := r.read(fs.log[stream[i]])
+		if err != nil {
+			return &[]core.Event{}, err
+		}
+		events = append(events, e)
+	}
+	return &events, nil
+}
+
+// Read returns the events appended after position, in append order.
+// A position is the number of events appended before it.
+func (fs *FileStore) Read(position string, c context.Context) ([]core.Event, string, error) {
+	from, err := parsePosition(position)
+	if err != nil {
+		return nil, position, err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	to := min(len(fs.log), from+readLimit)
+	if from >= to {
+		return nil, position, nil
+	}
+
+	events := make([]core.Event, 0, to-from)
+	r := reader{}
+	for i := from; i < to; i++ {
+		e, err := r.read(fs.log[i])
+		if err != nil {
+			return nil, position, err
+		}
+		events = append(events, e)
+	}
+	return events, strconv.Itoa(to), nil
+}
+
+// ReadByEntity returns the events of entity appended after position, in append
+// order. A position is the number of events appended before it.
+func (fs *FileStore) ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error) {
+	from, err := parsePosition(position)
+	if err != nil {
+		return nil, position, err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	events := []core.Event{}
+	r := reader{}
+	for i := from; i < len(fs.log) && len(events) < readLimit; i++ {
+		if fs.log[i].entity == entity {
+			e, err := r.read(fs.log[i])
+			if err != nil {
+				return nil, position, err
+			}
+			events = append(events, e)
+		}
+		position = strconv.Itoa(i + 1)
+	}
+	return &events, position, nil
+}
+
+func parsePosition(position string) (int, error) {
+	if position == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(position)
+}
@@ -0,0 +1,140 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/filestore"
+)
+
+func publish(t *testing.T, fs *filestore.FileStore, id string, versions ...int) {
+	es := eventstore.NewEventStore(fs, fs, core.NewDefaultTimer())
+	for _, v := range versions {
+		e, err := core.NewEvent(id, v, "Customer", "Renamed", map[string]string{"name": "test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := es.Publish(e, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectHead(t *testing.T, fs *filestore.FileStore, id string, version int) {
+	es := eventstore.NewEventStore(fs, fs, core.NewDefaultTimer())
+	events, err := es.LoadEvents(id, 0, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != version || (*events)[version-1].Version != version {
+		t.Fatalf("expected %d events, got %v", version, *events)
+	}
+}
+
+func TestReopen(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := filestore.Open(dir, filestore.WithSegmentSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, fs, "id", 1, 2, 3, 4, 5)
+	fs.Close()
+
+	segments, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	if len(segments) < 2 {
+		t.Fatalf("expected several segments, got %d", len(segments))
+	}
+
+	fs, err = filestore.Open(dir, filestore.WithSegmentSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	expectHead(t, fs, "id", 5)
+	publish(t, fs, "id", 6)
+	expectHead(t, fs, "id", 6)
+}
+
+func TestTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := filestore.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, fs, "id", 1, 2, 3)
+	fs.Close()
+
+	// cut the last record short, as a crash in the middle of a write would
+	segment := filepath.Join(dir, "0000000001.log")
+	info, err := os.Stat(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(segment, info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err = filestore.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	expectHead(t, fs, "id", 2)
+	publish(t, fs, "id", 3, 4)
+	expectHead(t, fs, "id", 4)
+}
+
+func TestCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := filestore.Open(dir, filestore.WithSegmentSize(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	publish(t, fs, "id", 1, 2, 3)
+	fs.Close()
+
+	// a damaged record that is not the last one cannot be the result of a crash
+	f, err := os.OpenFile(filepath.Join(dir, "0000000001.log"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteAt([]byte("xx"), 20)
+	f.Close()
+
+	if _, err := filestore.Open(dir); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestAppendInvalidVersion(t *testing.T) {
+	fs, err := filestore.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	ctx := context.Background()
+
+	if err := fs.AppendLastEvent(&core.Event{Id: "id", Version: 3}, ctx); !errors.Is(err, core.InvalidVersion{}) {
+		t.Errorf("expected invalid version, got %v", err)
+	}
+	first := &core.Event{Id: "id", Version: 1}
+	if err := fs.AppendLastEvent(first, ctx); err != nil {
+		t.Fatal(err)
+	}
+	batch := []*core.Event{{Id: "id", Version: 2}, {Id: "id", Version: 4}}
+	if err := fs.AppendEvents(first, batch, ctx); !errors.Is(err, core.InvalidVersion{}) {
+		t.Errorf("expected invalid version, got %v", err)
+	}
+	if err := fs.UpdateLastEvent(&core.Event{Id: "id", Version: 2}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	last, _ := fs.GetLastEvent("id", ctx)
+	if last.Version != 2 {
+		t.Errorf("expected the stream at version 2, got %d", last.Version)
+	}
+}
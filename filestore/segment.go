@@ -0,0 +1,124 @@
+package filestore
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// headerSize is the size of a record header: the length of the payload and its
+// checksum, both little endian uint32.
+const headerSize = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTornRecord reports a record that was not completely written.
+var errTornRecord = errors.New("torn record")
+
+// segment is an append-only file of records. Every record holds the events
+// appended by a single write, so a write is either read back whole or not at all.
+type segment struct {
+	number int
+	file   *os.File
+	size   int64
+}
+
+func segmentName(number int) string {
+	return fmt.Sprintf("%010d.log", number)
+}
+
+// segmentNumbers returns the numbers of the segments in dir, in ascending order.
+func segmentNumbers(dir string) ([]int, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	numbers := []int{}
+	for _, name := range names {
+		var number int
+		if _, err := fmt.Sscanf(filepath.Base(name), "%010d.log", &number); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+func openSegment(dir string, number int) (*segment, error) {
+	f, err := os.OpenFile(filepath.Join(dir, segmentName(number)), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &segment{number: number, file: f, size: info.Size()}, nil
+}
+
+func encodeRecord(events []core.Event) ([]byte, error) {
+	payload, err := json.Marshal(events)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, headerSize+len(payload))
+	binary.LittleEndian.PutUint32(record, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.Checksum(payload, crcTable))
+	copy(record[headerSize:], payload)
+	return record, nil
+}
+
+// append writes record at the end of the segment. A partially written record is
+// truncated so that the segment stays readable.
+func (s *segment) append(record []byte) (int64, error) {
+	offset := s.size
+	if _, err := s.file.WriteAt(record, offset); err != nil {
+		s.file.Truncate(offset)
+		return 0, err
+	}
+	s.size += int64(len(record))
+	return offset, nil
+}
+
+// read returns the events of the record at offset and the offset of the next record.
+// It returns errTornRecord if the record is incomplete or its checksum does not match.
+func (s *segment) read(offset int64) ([]core.Event, int64, error) {
+	header := make([]byte, headerSize)
+	if _, err := s.file.ReadAt(header, offset); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, err
+	}
+
+	length := int64(binary.LittleEndian.Uint32(header))
+	if offset+headerSize+length > s.size {
+		return nil, 0, errTornRecord
+	}
+
+	payload := make([]byte, length)
+	if _, err := s.file.ReadAt(payload, offset+headerSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, errTornRecord
+		}
+		return nil, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:]) {
+		return nil, 0, errTornRecord
+	}
+
+	var events []core.Event
+	if err := json.Unmarshal(payload, &events); err != nil {
+		return nil, 0, errTornRecord
+	}
+	return events, offset + headerSize + int64(len(payload)), nil
+}
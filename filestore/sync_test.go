@@ -0,0 +1,41 @@
+package filestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+func TestSyncEvery(t *testing.T) {
+	fs, err := Open(t.TempDir(), WithSyncPolicy(SyncEvery(100*time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	dirty := func() bool {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		return fs.dirty
+	}
+
+	// the first append is flushed, the one right after it is left to the
+	// background flush
+	for v := 1; v <= 2; v++ {
+		if err := fs.append(v-1, []*core.Event{{Id: "id", Version: v}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !dirty() {
+		t.Fatal("expected the second append not to be flushed yet")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for dirty() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the append to be flushed in the background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,126 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/grpcapi/eventstorepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Client is an event store served by a Server. It implements eventstore.Store,
+// so that a Repository can load and save aggregates through it.
+type Client struct {
+	client eventstorepb.EventStoreClient
+}
+
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{client: eventstorepb.NewEventStoreClient(cc)}
+}
+
+// Publish appends e to its stream, which must be at the version preceding e.
+func (cl *Client) Publish(e *core.Event, c context.Context) error {
+	return cl.PublishBatch([]*core.Event{e}, e.Version-1, c)
+}
+
+// PublishBatch appends events to their stream atomically, if the stream is at
+// expectedVersion. The events are updated with the values set by the server,
+// such as their creation time.
+func (cl *Client) PublishBatch(events []*core.Event, expectedVersion int, c context.Context) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	id := events[0].Id
+	req := &eventstorepb.AppendRequest{StreamId: id, ExpectedVersion: int64(expectedVersion)}
+	for i, e := range events {
+		if e.Id != id {
+			return core.InvalidBatch{}
+		}
+		if e.Version != expectedVersion+i+1 {
+			return core.InvalidVersion{}
+		}
+		req.Events = append(req.Events, newEventToProto(e))
+	}
+
+	var trailer metadata.MD
+	response, err := cl.client.Append(c, req, grpc.Trailer(&trailer))
+	if err != nil {
+		return storeError(err, id, expectedVersion, trailer, c)
+	}
+
+	for i, e := range response.Events {
+		if i < len(events) {
+			*events[i] = fromProto(e)
+		}
+	}
+	return nil
+}
+
+// LoadEvents returns the events of a stream after version v.
+func (cl *Client) LoadEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	stream, err := cl.client.Read(c, &eventstorepb.ReadRequest{StreamId: id, FromVersion: int64(v)})
+	if err != nil {
+		return nil, storeError(err, id, v, nil, c)
+	}
+
+	events := []core.Event{}
+	for {
+		e, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return &events, nil
+		}
+		if err != nil {
+			return nil, storeError(err, id, v, nil, c)
+		}
+		events = append(events, fromProto(e))
+	}
+}
+
+// Subscribe calls h with the events committed after position, and with a
+// position to resume from, until c is done, the call fails or h returns an
+// error. Entity, if not empty, only subscribes to the events of this entity.
+func (cl *Client) Subscribe(position string, entity string, h func(e *core.Event, position string) error, c context.Context) error {
+	stream, err := cl.client.Subscribe(c, &eventstorepb.SubscribeRequest{Position: position, Entity: entity})
+	if err != nil {
+		return storeError(err, "", 0, nil, c)
+	}
+
+	for {
+		response, err := stream.Recv()
+		if err != nil {
+			return storeError(err, "", 0, nil, c)
+		}
+		e := fromProto(response.Event)
+		if err := h(&e, response.Position); err != nil {
+			return err
+		}
+	}
+}
+
+// storeError returns the event store error of a failed call on a stream.
+func storeError(err error, id string, expected int, trailer metadata.MD, c context.Context) error {
+	if c.Err() != nil {
+		return c.Err()
+	}
+	switch status.Code(err) {
+	case codes.Aborted:
+		ce := core.ConcurrencyError{StreamId: id, Expected: expected}
+		if v := trailer.Get(actualVersionKey); len(v) > 0 {
+			ce.Actual, _ = strconv.Atoi(v[0])
+		}
+		return ce
+	case codes.OutOfRange:
+		return core.InvalidVersion{}
+	case codes.NotFound:
+		return core.EventsNotFound{}
+	default:
+		return err
+	}
+}
@@ -0,0 +1,67 @@
+package grpcapi
+
+import (
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/grpcapi/eventstorepb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toProto(e *core.Event) *eventstorepb.Event {
+	return &eventstorepb.Event{
+		Id:            e.Id,
+		Version:       int64(e.Version),
+		Entity:        e.Entity,
+		Action:        e.Action,
+		Created:       timestamppb.New(e.Created),
+		Payload:       e.Payload,
+		SchemaVersion: int32(e.SchemaVersion),
+		CorrelationId: e.CorrelationId,
+		CausationId:   e.CausationId,
+		Actor:         e.Actor,
+		Metadata:      e.Metadata,
+	}
+}
+
+func fromProto(p *eventstorepb.Event) core.Event {
+	return core.Event{
+		Id:            p.Id,
+		Version:       int(p.Version),
+		Entity:        p.Entity,
+		Action:        p.Action,
+		Created:       p.Created.AsTime(),
+		Payload:       p.Payload,
+		SchemaVersion: int(p.SchemaVersion),
+		CorrelationId: p.CorrelationId,
+		CausationId:   p.CausationId,
+		Actor:         p.Actor,
+		Metadata:      p.Metadata,
+	}
+}
+
+func newEventToProto(e *core.Event) *eventstorepb.NewEvent {
+	return &eventstorepb.NewEvent{
+		Entity:        e.Entity,
+		Action:        e.Action,
+		Payload:       e.Payload,
+		SchemaVersion: int32(e.SchemaVersion),
+		CorrelationId: e.CorrelationId,
+		CausationId:   e.CausationId,
+		Actor:         e.Actor,
+		Metadata:      e.Metadata,
+	}
+}
+
+func newEventFromProto(p *eventstorepb.NewEvent, id string, version int) *core.Event {
+	return &core.Event{
+		Id:            id,
+		Version:       version,
+		Entity:        p.Entity,
+		Action:        p.Action,
+		Payload:       p.Payload,
+		SchemaVersion: int(p.SchemaVersion),
+		CorrelationId: p.CorrelationId,
+		CausationId:   p.CausationId,
+		Actor:         p.Actor,
+		Metadata:      p.Metadata,
+	}
+}
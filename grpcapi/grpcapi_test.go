@@ -0,0 +1,153 @@
+package grpcapi_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/grpcapi"
+	"github.com/akkgr/eventstore/grpcapi/eventstorepb"
+	"github.com/akkgr/eventstore/memorystore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T) *grpcapi.Client {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	eventstorepb.RegisterEventStoreServer(s, grpcapi.NewServer(es,
+		grpcapi.WithSource(ms), grpcapi.WithPollInterval(10*time.Millisecond)))
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(c context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(c)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return grpcapi.NewClient(cc)
+}
+
+func newEvents(t *testing.T, id string, from int, n int) []*core.Event {
+	var events []*core.Event
+	for v := from + 1; v <= from+n; v++ {
+		e, err := core.NewEvent(id, v, "Customer", customer.CustomerUpdated,
+			customer.CustomerUpdatedEvent{Name: "John Doe", Status: "Active"}, core.WithActor("admin"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestAppendAndRead(t *testing.T) {
+	cl := newClient(t)
+	ctx := context.Background()
+
+	if err := cl.PublishBatch(newEvents(t, "id", 0, 2), 0, ctx); err != nil {
+		t.Fatal(err)
+	}
+	events := newEvents(t, "id", 2, 1)
+	if err := cl.Publish(events[0], ctx); err != nil {
+		t.Fatal(err)
+	}
+	if events[0].Created.IsZero() {
+		t.Error("expected the event to be updated by the server")
+	}
+
+	loaded, err := cl.LoadEvents("id", 1, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*loaded) != 2 || (*loaded)[1].Version != 3 || (*loaded)[1].Actor != "admin" {
+		t.Errorf("unexpected events %+v", *loaded)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	cl := newClient(t)
+	ctx := context.Background()
+
+	if err := cl.PublishBatch(newEvents(t, "id", 0, 2), 0, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	err := cl.PublishBatch(newEvents(t, "id", 1, 2), 1, ctx)
+	if ce := (core.ConcurrencyError{}); !errors.As(err, &ce) || ce.Actual != 2 || ce.Expected != 1 {
+		t.Errorf("expected a concurrency error at version 2, got %v", err)
+	}
+
+	if _, err := cl.LoadEvents("missing", 0, ctx); !errors.Is(err, core.EventsNotFound{}) {
+		t.Errorf("expected events not found, got %v", err)
+	}
+	if _, err := cl.LoadEvents("id", 5, ctx); !errors.Is(err, core.InvalidVersion{}) {
+		t.Errorf("expected an invalid version, got %v", err)
+	}
+}
+
+func TestRepository(t *testing.T) {
+	cl := newClient(t)
+	ctx := context.Background()
+
+	repo := eventstore.NewRepository(cl, func() *customer.Customer { return &customer.Customer{} })
+	created, _ := core.NewEvent("id", 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John Doe"})
+	if err := repo.Save(repo.New(), []*core.Event{created}, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := repo.Load("id", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "John Doe" || c.GetVersion() != 1 {
+		t.Errorf("unexpected customer %+v", c)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	cl := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := cl.PublishBatch(newEvents(t, "id", 0, 2), 0, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var versions []int
+	var last string
+	err := cl.Subscribe("", "", func(e *core.Event, position string) error {
+		versions = append(versions, e.Version)
+		last = position
+		if e.Version == 2 {
+			// published while subscribed
+			return cl.PublishBatch(newEvents(t, "id", 2, 1), 2, ctx)
+		}
+		if e.Version == 3 {
+			cancel()
+		}
+		return nil
+	}, ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the subscription to be cancelled, got %v", err)
+	}
+	if len(versions) != 3 || versions[2] != 3 {
+		t.Errorf("expected versions 1 to 3, got %v", versions)
+	}
+	if last != "3" {
+		t.Errorf("expected to resume after 3 events, got %q", last)
+	}
+}
@@ -0,0 +1,167 @@
+// Package grpcapi exposes an event store over gRPC, with the EventStore
+// service of eventstorepb/eventstore.proto.
+//
+// The Go code in eventstorepb is generated by running buf generate in this
+// directory, with protoc-gen-go and protoc-gen-go-grpc on the PATH.
+package grpcapi
+
+//go:generate buf generate
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/grpcapi/eventstorepb"
+	"github.com/akkgr/eventstore/subscription"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// actualVersionKey is the trailer that holds the actual version of a stream
+// when an append fails with a version conflict.
+const actualVersionKey = "actual-version"
+
+// Server implements the EventStore service on top of an event store.
+// Register it with eventstorepb.RegisterEventStoreServer.
+type Server struct {
+	eventstorepb.UnimplementedEventStoreServer
+	store    eventstore.Store
+	source   subscription.Source
+	interval time.Duration
+}
+
+type Option func(*Server)
+
+// WithSource sets the source of Subscribe, which is unimplemented without one.
+func WithSource(s subscription.Source) Option {
+	return func(srv *Server) {
+		srv.source = s
+	}
+}
+
+// WithPollInterval sets the time Subscribe waits for new events,
+// subscription.DefaultPollInterval by default.
+func WithPollInterval(d time.Duration) Option {
+	return func(srv *Server) {
+		srv.interval = d
+	}
+}
+
+func NewServer(s eventstore.Store, opts ...Option) *Server {
+	srv := &Server{
+		store:    s,
+		interval: subscription.DefaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	return srv
+}
+
+func (srv *Server) Append(c context.Context, req *eventstorepb.AppendRequest) (*eventstorepb.AppendResponse, error) {
+	if req.StreamId == "" || len(req.Events) == 0 || req.ExpectedVersion < 0 {
+		return nil, status.Error(codes.InvalidArgument, "a stream id, an expected version and events are required")
+	}
+
+	expected := int(req.ExpectedVersion)
+	events := make([]*core.Event, len(req.Events))
+	for i, e := range req.Events {
+		events[i] = newEventFromProto(e, req.StreamId, expected+i+1)
+	}
+
+	if err := srv.store.PublishBatch(events, expected, c); err != nil {
+		return nil, statusError(err, c)
+	}
+
+	response := &eventstorepb.AppendResponse{Version: int64(expected + len(events))}
+	for _, e := range events {
+		response.Events = append(response.Events, toProto(e))
+	}
+	return response, nil
+}
+
+func (srv *Server) Read(req *eventstorepb.ReadRequest, stream grpc.ServerStreamingServer[eventstorepb.Event]) error {
+	c := stream.Context()
+	events, err := srv.store.LoadEvents(req.StreamId, int(req.FromVersion), c)
+	if err != nil {
+		return statusError(err, c)
+	}
+
+	for i := range *events {
+		if err := stream.Send(toProto(&(*events)[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (srv *Server) Subscribe(req *eventstorepb.SubscribeRequest, stream grpc.ServerStreamingServer[eventstorepb.SubscribeResponse]) error {
+	if srv.source == nil {
+		return status.Error(codes.Unimplemented, "subscribe not supported")
+	}
+
+	c := stream.Context()
+	position := req.Position
+	for {
+		events, next, err := srv.source.Read(position, c)
+		if err != nil {
+			return statusError(err, c)
+		}
+
+		for i := range events {
+			if req.Entity != "" && events[i].Entity != req.Entity {
+				continue
+			}
+			// resuming from the start of a batch delivers all of its events
+			resume := position
+			if i == len(events)-1 {
+				resume = next
+			}
+			err := stream.Send(&eventstorepb.SubscribeResponse{Event: toProto(&events[i]), Position: resume})
+			if err != nil {
+				return err
+			}
+		}
+		position = next
+		if len(events) > 0 {
+			continue
+		}
+
+		t := time.NewTimer(srv.interval)
+		select {
+		case <-c.Done():
+			t.Stop()
+			return status.FromContextError(c.Err()).Err()
+		case <-t.C:
+		}
+	}
+}
+
+// statusError returns the gRPC status of an error of the event store.
+func statusError(err error, c context.Context) error {
+	var ce core.ConcurrencyError
+	switch {
+	case errors.As(err, &ce):
+		grpc.SetTrailer(c, metadata.Pairs(actualVersionKey, strconv.Itoa(ce.Actual)))
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, core.InvalidVersion{}):
+		// the version read from is after the last event
+		return status.Error(codes.OutOfRange, err.Error())
+	case errors.Is(err, core.EventsNotFound{}):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, core.InvalidPayload{}), errors.Is(err, core.InvalidBatch{}):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &core.NotSupported{}):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// NewEvent is an event to append, as sent in the body of a POST request.
+// The stream id and the version are taken from the request.
+type NewEvent struct {
+	Entity        string            `json:"entity"`
+	Action        string            `json:"action"`
+	Payload       json.RawMessage   `json:"payload"`
+	CorrelationId string            `json:"correlationId,omitempty"`
+	CausationId   string            `json:"causationId,omitempty"`
+	Actor         string            `json:"actor,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// Event is a stored event, as returned in the body of a response.
+type Event struct {
+	Id            string            `json:"id"`
+	Version       int               `json:"version"`
+	Entity        string            `json:"entity"`
+	Action        string            `json:"action"`
+	Created       time.Time         `json:"created"`
+	Payload       json.RawMessage   `json:"payload"`
+	SchemaVersion int               `json:"schemaVersion"`
+	CorrelationId string            `json:"correlationId,omitempty"`
+	CausationId   string            `json:"causationId,omitempty"`
+	Actor         string            `json:"actor,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+// Error is the body of an error response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+func (e *NewEvent) event(id string, version int) *core.Event {
+	return &core.Event{
+		Id:            id,
+		Version:       version,
+		Entity:        e.Entity,
+		Action:        e.Action,
+		Payload:       e.Payload,
+		CorrelationId: e.CorrelationId,
+		CausationId:   e.CausationId,
+		Actor:         e.Actor,
+		Metadata:      e.Metadata,
+	}
+}
+
+func newEvent(e *core.Event) Event {
+	payload := json.RawMessage(e.Payload)
+	if !json.Valid(payload) {
+		// events that were not stored through the API may have any payload
+		payload, _ = json.Marshal(e.Payload)
+	}
+	return Event{
+		Id:            e.Id,
+		Version:       e.Version,
+		Entity:        e.Entity,
+		Action:        e.Action,
+		Created:       e.Created,
+		Payload:       payload,
+		SchemaVersion: e.SchemaVersion,
+		CorrelationId: e.CorrelationId,
+		CausationId:   e.CausationId,
+		Actor:         e.Actor,
+		Metadata:      e.Metadata,
+	}
+}
@@ -0,0 +1,189 @@
+// Package httpapi exposes an event store over HTTP.
+//
+// The server has two endpoints:
+//
+//	POST /streams/{id}/events        appends a JSON array of NewEvent
+//	GET  /streams/{id}/events?from=v returns the events after version v
+//
+// The version of a stream is its ETag. An append must either send the version
+// it expects in If-Match, or If-None-Match: * to create the stream. A response
+// carries the version of the stream in its ETag header.
+//
+// An append that does not match the version of the stream fails with 409
+// Conflict. A read from a version after the last event of the stream fails with
+// 416 Range Not Satisfiable.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+)
+
+// DefaultMaxBodySize is the maximum size of the body of a POST request.
+const DefaultMaxBodySize = 1 << 20
+
+type Server struct {
+	store       eventstore.Store
+	mux         *http.ServeMux
+	maxBodySize int64
+}
+
+type Option func(*Server)
+
+// WithMaxBodySize sets the maximum size of the body of a POST request,
+// DefaultMaxBodySize by default.
+func WithMaxBodySize(n int64) Option {
+	return func(s *Server) {
+		s.maxBodySize = n
+	}
+}
+
+// NewServer returns a handler that serves the streams of s.
+func NewServer(s eventstore.Store, opts ...Option) *Server {
+	srv := &Server{
+		store:       s,
+		mux:         http.NewServeMux(),
+		maxBodySize: DefaultMaxBodySize,
+	}
+	for _, opt := range opts {
+		opt(srv)
+	}
+	srv.mux.HandleFunc("POST /streams/{id}/events", srv.append)
+	srv.mux.HandleFunc("GET /streams/{id}/events", srv.read)
+	return srv
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) append(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	expected, err := expectedVersion(r)
+	if err != nil {
+		writeError(w, http.StatusPreconditionRequired, err)
+		return
+	}
+
+	var body []NewEvent
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, s.maxBodySize))
+	if err := dec.Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+	if len(body) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no events"))
+		return
+	}
+
+	events := make([]*core.Event, len(body))
+	for i := range body {
+		if body[i].Entity == "" || body[i].Action == "" || len(body[i].Payload) == 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("event %d needs an entity, an action and a payload", i))
+			return
+		}
+		events[i] = body[i].event(id, expected+i+1)
+	}
+
+	if err := s.store.PublishBatch(events, expected, r.Context()); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	stored := make([]Event, len(events))
+	for i, e := range events {
+		stored[i] = newEvent(e)
+	}
+	w.Header().Set("ETag", etag(expected+len(events)))
+	writeJSON(w, http.StatusCreated, stored)
+}
+
+func (s *Server) read(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	from := 0
+	if v := r.URL.Query().Get("from"); v != "" {
+		var err error
+		if from, err = strconv.Atoi(v); err != nil || from < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid from version %q", v))
+			return
+		}
+	}
+
+	events, err := s.store.LoadEvents(id, from, r.Context())
+	if errors.Is(err, core.InvalidVersion{}) {
+		// the version read from is after the last event
+		writeError(w, http.StatusRequestedRangeNotSatisfiable, err)
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	version := from
+	body := make([]Event, len(*events))
+	for i := range *events {
+		body[i] = newEvent(&(*events)[i])
+		version = body[i].Version
+	}
+	w.Header().Set("ETag", etag(version))
+	writeJSON(w, http.StatusOK, body)
+}
+
+// expectedVersion returns the version an append expects the stream to be at.
+func expectedVersion(r *http.Request) (int, error) {
+	if m := r.Header.Get("If-Match"); m != "" {
+		v, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(m, "W/"), `"`))
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid If-Match %q", m)
+		}
+		return v, nil
+	}
+	if r.Header.Get("If-None-Match") == "*" {
+		return 0, nil
+	}
+	return 0, errors.New("If-Match or If-None-Match: * is required")
+}
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// writeStoreError writes the response for an error of the event store.
+func writeStoreError(w http.ResponseWriter, err error) {
+	var ce core.ConcurrencyError
+	switch {
+	case errors.As(err, &ce):
+		w.Header().Set("ETag", etag(ce.Actual))
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, core.InvalidVersion{}):
+		writeError(w, http.StatusConflict, err)
+	case errors.Is(err, core.EventsNotFound{}):
+		writeError(w, http.StatusNotFound, err)
+	case errors.Is(err, core.InvalidPayload{}), errors.Is(err, core.InvalidBatch{}):
+		writeError(w, http.StatusBadRequest, err)
+	case errors.As(err, &core.NotSupported{}):
+		writeError(w, http.StatusNotImplemented, err)
+	default:
+		writeError(w, http.StatusInternalServerError, errors.New(http.StatusText(http.StatusInternalServerError)))
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
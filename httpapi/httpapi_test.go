@@ -0,0 +1,123 @@
+package httpapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/httpapi"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+const created = `[{"entity":"Customer","action":"CustomerCreated","payload":{"Name":"John Doe"}}]`
+
+const updated = `[
+	{"entity":"Customer","action":"CustomerUpdated","payload":{"Status":"Active"}},
+	{"entity":"Customer","action":"CustomerUpdated","payload":{"Status":"Inactive"},"actor":"admin"}
+]`
+
+func newServer() *httpapi.Server {
+	ms := memorystore.NewMemoryStore()
+	return httpapi.NewServer(eventstore.NewEventStore(ms, ms, core.NewDefaultTimer()))
+}
+
+func do(srv http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, r)
+	return w
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) []httpapi.Event {
+	var events []httpapi.Event
+	if err := json.Unmarshal(w.Body.Bytes(), &events); err != nil {
+		t.Fatal(err)
+	}
+	return events
+}
+
+func TestAppendAndRead(t *testing.T) {
+	srv := newServer()
+
+	w := do(srv, "POST", "/streams/id/events", created, "If-None-Match", "*")
+	if w.Code != http.StatusCreated || w.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected the stream to be created, got %d %s", w.Code, w.Body)
+	}
+
+	w = do(srv, "POST", "/streams/id/events", updated, "If-Match", `"1"`)
+	if w.Code != http.StatusCreated || w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected the events to be appended, got %d %s", w.Code, w.Body)
+	}
+	if events := decode(t, w); len(events) != 2 || events[1].Version != 3 || events[1].Actor != "admin" {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	w = do(srv, "GET", "/streams/id/events", "")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"3"` {
+		t.Fatalf("expected the events, got %d %s", w.Code, w.Body)
+	}
+	events := decode(t, w)
+	if len(events) != 3 || string(events[0].Payload) != `{"Name":"John Doe"}` {
+		t.Errorf("unexpected events %+v", events)
+	}
+
+	w = do(srv, "GET", "/streams/id/events?from=2", "")
+	if events := decode(t, w); len(events) != 1 || events[0].Version != 3 {
+		t.Errorf("expected the events after version 2, got %+v", events)
+	}
+
+	w = do(srv, "GET", "/streams/id/events?from=3", "")
+	if events := decode(t, w); w.Code != http.StatusOK || len(events) != 0 || w.Header().Get("ETag") != `"3"` {
+		t.Errorf("expected no events, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	srv := newServer()
+	do(srv, "POST", "/streams/id/events", created, "If-None-Match", "*")
+
+	w := do(srv, "POST", "/streams/id/events", created, "If-None-Match", "*")
+	if w.Code != http.StatusConflict || w.Header().Get("ETag") != `"1"` {
+		t.Errorf("expected a conflict at version 1, got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	w = do(srv, "POST", "/streams/id/events", updated, "If-Match", `"2"`)
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected a conflict, got %d", w.Code)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := newServer()
+	do(srv, "POST", "/streams/head/events", created, "If-None-Match", "*")
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		status int
+	}{
+		{"NotFound", do(srv, "GET", "/streams/missing/events", ""), http.StatusNotFound},
+		{"InvalidFrom", do(srv, "GET", "/streams/id/events?from=x", ""), http.StatusBadRequest},
+		{"FromAfterHead", do(srv, "GET", "/streams/head/events?from=5", ""), http.StatusRequestedRangeNotSatisfiable},
+		{"NoPrecondition", do(srv, "POST", "/streams/id/events", created), http.StatusPreconditionRequired},
+		{"InvalidBody", do(srv, "POST", "/streams/id/events", `{`, "If-None-Match", "*"), http.StatusBadRequest},
+		{"NoEvents", do(srv, "POST", "/streams/id/events", `[]`, "If-None-Match", "*"), http.StatusBadRequest},
+		{"NoAction", do(srv, "POST", "/streams/id/events", `[{"entity":"Customer","payload":{}}]`, "If-None-Match", "*"), http.StatusBadRequest},
+		{"Method", do(srv, "DELETE", "/streams/id/events", ""), http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.w.Code != tt.status {
+				t.Errorf("expected %d, got %d %s", tt.status, tt.w.Code, tt.w.Body)
+			}
+		})
+	}
+}
@@ -1,93 +1,99 @@
+// Command eventstore inspects and maintains an event store.
+//
+// Usage:
+//
+//	eventstore [flags] <command> [arguments]
+//
+// The commands are:
+//
+//	tables create [-on-demand]            create the tables of the backend
+//	stream show <id>                      print the events of a stream and its state
+//	stream tail [-all] [-entity e]        print events as they are committed
+//	export [file]                         write all events as JSON lines
+//	import [file]                         publish the events of a JSON lines file
+//	verify                                check the consistency of all streams
+//
+// The flags select the backend, DynamoDB by default; run eventstore -h for
+// the list.
 package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"time"
-
-	"github.com/akkgr/eventstore/core"
-	"github.com/akkgr/eventstore/customer"
-	"github.com/akkgr/eventstore/dynamodbstore"
-	"github.com/akkgr/eventstore/eventstore"
-	"github.com/akkgr/eventstore/properties"
-	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
+	"io"
+	"os"
+	"os/signal"
 )
 
-func main() {
-	ctx := context.TODO()
-	dbc := dynamodbstore.NewDynamoDBClient(ctx, true)
-	createTables := flag.Bool("tbl", false, "create tables")
-	flag.Parse()
+const usage = `usage: eventstore [flags] <command> [arguments]
 
-	if *createTables {
-		err := dynamodbstore.CreateTables(dbc)
-		if err != nil {
-			panic(err)
-		}
-	}
+commands:
+  tables create [-on-demand]       create the tables of the backend
+  stream show <id>                 print the events of a stream and its state
+  stream tail [-all] [-entity e]   print events as they are committed
+  export [file]                    write all events as JSON lines
+  import [file]                    publish the events of a JSON lines file
+  verify                           check the consistency of all streams
 
-	myTimer := core.NewDefaultTimer()
-	es := eventstore.NewEventStore(dbc, dbc, myTimer)
+flags:
+`
 
-	// create some events
-	id := uuid.New().String()
-	command1 := customer.CustomerCreatedEvent{
-		Name: "John Doe",
-	}
-	e1, _ := core.NewEvent(id, 1, "Customer", "CustomerCreated", command1)
+// errUsage reports a command line that does not match the usage.
+var errUsage = errors.New("invalid usage, run eventstore -h for help")
 
-	command2 := customer.CustomerUpdatedEvent{
-		Name:   "John Doe",
-		Status: "Active",
-	}
-	e2, _ := core.NewEvent(id, 2, "Customer", "CustomerUpdated", command2)
+func main() {
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	command3 := customer.CustomerUpdatedEvent{
-		Name:   "John Doe",
-		Status: "Inactive",
-		Data: properties.Data{
-			Properties: map[string]properties.Property{
-				"age":         properties.NewNumberProperty(decimal.NewFromInt(42)),
-				"nationality": properties.NewTextProperty("American"),
-				"birthday":    properties.NewDateProperty(time.Date(2000, 3, 15, 0, 0, 0, 0, time.UTC)),
-			},
-			Collections: map[string]properties.Collection{
-				"addresses": map[string]properties.Properties{
-					"home": map[string]properties.Property{
-						"street":     properties.NewTextProperty("123 Main St."),
-						"appartment": properties.NewTextProperty("Apt. 42"),
-						"area":       properties.NewTextProperty("Springfield, IL 62701"),
-					},
-				},
-			},
-		},
+	err := run(os.Args[1:], os.Stdin, os.Stdout, c)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, "eventstore:", err)
+		os.Exit(1)
 	}
-	e3, _ := core.NewEvent(id, 3, "Customer", "CustomerUpdated", command3)
-
-	// publish the events
-	es.Publish(e1, ctx)
-	es.Publish(e2, ctx)
-	es.Publish(e3, ctx)
+}
 
-	// read the events
-	events, _ := es.LoadEvents(id, 0, ctx)
-	prettyPrint(events)
+func run(args []string, stdin io.Reader, stdout io.Writer, c context.Context) error {
+	fs := flag.NewFlagSet("eventstore", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	cfg := &config{}
+	cfg.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
 
-	// construct the customer from the events
-	c := customer.Customer{}
-	for _, e := range *events {
-		c.Apply(&e)
+	args = fs.Args()
+	if len(args) == 0 {
+		fs.Usage()
+		return errUsage
 	}
-	prettyPrint(c)
-}
 
-func prettyPrint(obj any) {
-	b, err := json.MarshalIndent(obj, "", "  ")
+	b, err := openBackend(cfg, c)
 	if err != nil {
-		fmt.Println(err)
+		return err
+	}
+	defer b.close()
+
+	cmd := &command{backend: b, stdin: stdin, stdout: stdout}
+	switch {
+	case len(args) >= 2 && args[0] == "tables" && args[1] == "create":
+		return cmd.createTables(args[2:], c)
+	case len(args) >= 2 && args[0] == "stream" && args[1] == "show":
+		return cmd.show(args[2:], c)
+	case len(args) >= 2 && args[0] == "stream" && args[1] == "tail":
+		return cmd.tail(args[2:], c)
+	case args[0] == "export":
+		return cmd.export(args[1:], c)
+	case args[0] == "import":
+		return cmd.importEvents(args[1:], c)
+	case args[0] == "verify":
+		return cmd.verify(args[1:], c)
+	default:
+		fs.Usage()
+		return errUsage
 	}
-	fmt.Println(string(b))
 }
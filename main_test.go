@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/filestore"
+	"github.com/akkgr/eventstore/sqlitestore"
+)
+
+func publishCustomer(t *testing.T, es *eventstore.EventStore, id string) {
+	e1, _ := core.NewEvent(id, 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John Doe"})
+	e2, _ := core.NewEvent(id, 2, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: "John Doe", Status: "Active"})
+	for _, e := range []*core.Event{e1, e2} {
+		if err := es.Publish(e, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func runCommand(t *testing.T, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	err := run(args, strings.NewReader(""), &stdout, context.Background())
+	return stdout.String(), err
+}
+
+func TestExportImport(t *testing.T) {
+	from, to := t.TempDir(), t.TempDir()
+	fs, err := filestore.Open(from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	es := eventstore.NewEventStore(fs, fs, core.NewDefaultTimer())
+	publishCustomer(t, es, "first")
+	publishCustomer(t, es, "second")
+	fs.Close()
+
+	exported := filepath.Join(t.TempDir(), "events.jsonl")
+	if _, err := runCommand(t, "-backend", "file", "-path", from, "export", exported); err != nil {
+		t.Fatal(err)
+	}
+	out, err := runCommand(t, "-backend", "file", "-path", to, "import", exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "imported 4 events\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	out, err = runCommand(t, "-backend", "file", "-path", to, "export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(exported)
+	if out != string(b) {
+		t.Errorf("expected the imported events to match the exported ones:\n%s\n%s", b, out)
+	}
+
+	out, err = runCommand(t, "-backend", "file", "-path", to, "verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "verified 2 streams, found 0 problems\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	out, err = runCommand(t, "-backend", "file", "-path", to, "stream", "show", "second")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "state:") || !strings.Contains(out, `"Status": "Active"`) {
+		t.Errorf("expected the state of the customer, got %s", out)
+	}
+}
+
+func TestVerifyGap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	if _, err := runCommand(t, "-backend", "sqlite", "-path", path, "tables", "create"); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := sqlitestore.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publishCustomer(t, eventstore.NewEventStore(s, s, core.NewDefaultTimer()), "id")
+	s.Close()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`INSERT INTO events (id, version, entity, action, created, payload)
+		VALUES ('id', 4, 'Customer', 'CustomerUpdated', '2000-03-15T00:00:00Z', '{}')`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runCommand(t, "-backend", "sqlite", "-path", path, "verify")
+	if !errors.Is(err, errInconsistent) {
+		t.Fatalf("expected inconsistent streams, got %v", err)
+	}
+	if !strings.Contains(out, "stream id: expected event 3, found last event 4") {
+		t.Errorf("expected the gap to be reported, got %q", out)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	if _, err := runCommand(t, "-backend", "file", "-path", t.TempDir(), "stream"); !errors.Is(err, errUsage) {
+		t.Errorf("expected a usage error, got %v", err)
+	}
+	if _, err := runCommand(t, "-backend", "nosql", "verify"); err == nil {
+		t.Error("expected an unknown backend error")
+	}
+}
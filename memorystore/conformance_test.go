@@ -0,0 +1,14 @@
+package memorystore_test
+
+import (
+	"testing"
+
+	"github.com/akkgr/eventstore/eventstoretest"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+func TestConformance(t *testing.T) {
+	eventstoretest.Run(t, func(t *testing.T) eventstoretest.Backend {
+		return memorystore.NewMemoryStore()
+	})
+}
@@ -0,0 +1,310 @@
+package memorystore
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// readLimit is the maximum number of events returned by a single Read.
+const readLimit = 100
+
+// MemoryStore is an in-memory implementation of the event store reader and
+// writer interfaces. It mirrors the layout of the DynamoDB backend: the last
+// event of every aggregate is kept apart from the events that preceded it.
+// It also keeps a log of all events in the order they were committed, which
+// subscriptions read through Read.
+// It is safe for concurrent use.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	lastEvents  map[string]core.Event
+	events      map[string]map[int]core.Event
+	snapshots   map[string]core.Snapshot
+	log         []core.Event
+	checkpoints map[string]string
+	projected   map[string]map[string]int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		lastEvents:  make(map[string]core.Event),
+		events:      make(map[string]map[int]core.Event),
+		snapshots:   make(map[string]core.Snapshot),
+		checkpoints: make(map[string]string),
+		projected:   make(map[string]map[string]int),
+	}
+}
+
+// clone returns a copy of e that does not share its payload or metadata.
+func clone(e *core.Event) core.Event {
+	c := *e
+	if e.Payload != nil {
+		c.Payload = append([]byte(nil), e.Payload...)
+	}
+	if e.Metadata != nil {
+		c.Metadata = make(map[string]string, len(e.Metadata))
+		for k, v := range e.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return c
+}
+
+func (ms *MemoryStore) appendEvent(e *core.Event) {
+	stream, ok := ms.events[e.Id]
+	if !ok {
+		stream = make(map[int]core.Event)
+		ms.events[e.Id] = stream
+	}
+	stream[e.Version] = clone(e)
+}
+
+// setLastEvent stores e as the last event of its aggregate and adds it to the log.
+func (ms *MemoryStore) setLastEvent(e *core.Event) {
+	ms.lastEvents[e.Id] = clone(e)
+	ms.log = append(ms.log, clone(e))
+}
+
+// AppendLastEvent stores the first event of the aggregate, but only if the
+// aggregate has no last event yet.
+func (ms *MemoryStore) AppendLastEvent(e *core.Event, c context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if last, ok := ms.lastEvents[e.Id]; ok {
+		return core.ConcurrencyError{StreamId: e.Id, Expected: 0, Actual: last.Version}
+	}
+	ms.setLastEvent(e)
+	return nil
+}
+
+// UpdateLastEvent replaces the last event of the aggregate with e, but only if
+// the stored last event has the version preceding e.
+func (ms *MemoryStore) UpdateLastEvent(e *core.Event, c context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	last, ok := ms.lastEvents[e.Id]
+	if !ok || last.Version != e.Version-1 {
+		return core.ConcurrencyError{StreamId: e.Id, Expected: e.Version - 1, Actual: last.Version}
+	}
+	ms.setLastEvent(e)
+	return nil
+}
+
+func (ms *MemoryStore) AppendEvent(e *core.Event, c context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.appendEvent(e)
+	return nil
+}
+
+// TransactLastEvent moves last to the events and replaces it with e, but only
+// if the stored last event still has the version of last.
+func (ms *MemoryStore) TransactLastEvent(last *core.Event, e *core.Event, c context.Context) error {
+	return ms.AppendEvents(last, []*core.Event{e}, c)
+}
+
+// AppendEvents moves last, if any, and all but the final event to the events and
+// stores the final event as the last event, but only if the stored last event
+// still has the version of last, or if there is none when last is nil.
+func (ms *MemoryStore) AppendEvents(last *core.Event, events []*core.Event, c context.Context) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	id := events[0].Id
+	expected := 0
+	if last != nil {
+		expected = last.Version
+	}
+	if current := ms.lastEvents[id]; current.Version != expected {
+		return core.ConcurrencyError{StreamId: id, Expected: expected, Actual: current.Version}
+	}
+
+	if last != nil {
+		ms.appendEvent(last)
+	}
+	for _, e := range events[:len(events)-1] {
+		ms.appendEvent(e)
+		ms.log = append(ms.log, clone(e))
+	}
+	ms.setLastEvent(events[len(events)-1])
+	return nil
+}
+
+func (ms *MemoryStore) GetLastEvent(id string, c context.Context) (*core.Event, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	last, ok := ms.lastEvents[id]
+	if !ok {
+		return &core.Event{}, nil
+	}
+	item := clone(&last)
+	return &item, nil
+}
+
+func (ms *MemoryStore) GetEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	var events []core.Event
+	for version, e := range ms.events[id] {
+		if version > v {
+			events = append(events, clone(&e))
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Version < events[j].Version
+	})
+
+	return &events, nil
+}
+
+// ListStreams returns the ids of the aggregates after position, in id order.
+// A position is the last returned id; the returned position is empty once all
+// ids have been listed.
+func (ms *MemoryStore) ListStreams(position string, c context.Context) ([]string, string, error) {
+	ms.mu.RLock()
+	ids := make([]string, 0, len(ms.lastEvents))
+	for id := range ms.lastEvents {
+		if id > position {
+			ids = append(ids, id)
+		}
+	}
+	ms.mu.RUnlock()
+
+	sort.Strings(ids)
+	if len(ids) <= readLimit {
+		return ids, "", nil
+	}
+	ids = ids[:readLimit]
+	return ids, ids[readLimit-1], nil
+}
+
+// SaveSnapshot stores s unless the stored snapshot of the aggregate is newer.
+func (ms *MemoryStore) SaveSnapshot(s *core.Snapshot, c context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if current, ok := ms.snapshots[s.Id]; ok && current.Version >= s.Version {
+		return nil
+	}
+	snapshot := *s
+	snapshot.State = append([]byte(nil), s.State...)
+	ms.snapshots[s.Id] = snapshot
+	return nil
+}
+
+func (ms *MemoryStore) GetSnapshot(id string, c context.Context) (*core.Snapshot, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	s, ok := ms.snapshots[id]
+	if !ok {
+		return &core.Snapshot{}, nil
+	}
+	s.State = append([]byte(nil), s.State...)
+	return &s, nil
+}
+
+// Read returns the events committed after position, in commit order.
+// A position is the number of events committed before it.
+func (ms *MemoryStore) Read(position string, c context.Context) ([]core.Event, string, error) {
+	from := 0
+	if position != "" {
+		var err error
+		if from, err = strconv.Atoi(position); err != nil {
+			return nil, position, err
+		}
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	to := min(len(ms.log), from+readLimit)
+	if from >= to {
+		return nil, position, nil
+	}
+
+	events := make([]core.Event, 0, to-from)
+	for i := from; i < to; i++ {
+		events = append(events, clone(&ms.log[i]))
+	}
+	return events, strconv.Itoa(to), nil
+}
+
+// ReadByEntity returns the events of entity committed after position, in commit
+// order. A position is the number of events committed before it.
+func (ms *MemoryStore) ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error) {
+	from := 0
+	if position != "" {
+		var err error
+		if from, err = strconv.Atoi(position); err != nil {
+			return nil, position, err
+		}
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	events := []core.Event{}
+	for i := from; i < len(ms.log) && len(events) < readLimit; i++ {
+		if ms.log[i].Entity == entity {
+			events = append(events, clone(&ms.log[i]))
+		}
+		position = strconv.Itoa(i + 1)
+	}
+	return &events, position, nil
+}
+
+func (ms *MemoryStore) SaveCheckpoint(name string, position string, c context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.checkpoints[name] = position
+	return nil
+}
+
+func (ms *MemoryStore) GetCheckpoint(name string, c context.Context) (string, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.checkpoints[name], nil
+}
+
+func (ms *MemoryStore) SaveProjectedVersion(name string, id string, version int, c context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	versions, ok := ms.projected[name]
+	if !ok {
+		versions = make(map[string]int)
+		ms.projected[name] = versions
+	}
+	versions[id] = version
+	return nil
+}
+
+func (ms *MemoryStore) GetProjectedVersion(name string, id string, c context.Context) (int, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.projected[name][id], nil
+}
+
+func (ms *MemoryStore) ClearProjectedVersions(name string, c context.Context) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	delete(ms.projected, name)
+	return nil
+}
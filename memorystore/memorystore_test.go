@@ -0,0 +1,66 @@
+package memorystore_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+)
+
+func TestPublishAndLoad(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+
+	for v := 1; v <= 3; v++ {
+		e, _ := core.NewEvent("id", v, "test", "test", v)
+		if err := es.Publish(e, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := es.LoadEvents("id", 0, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(*events))
+	}
+	for i, e := range *events {
+		if e.Version != i+1 {
+			t.Errorf("expected version %d, got %d", i+1, e.Version)
+		}
+	}
+}
+
+func TestUpdateLastEventVersionMismatch(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	ctx := context.Background()
+
+	if err := ms.AppendLastEvent(&core.Event{Id: "id", Version: 1}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ms.UpdateLastEvent(&core.Event{Id: "id", Version: 3}, ctx); err == nil {
+		t.Error("expected an error")
+	}
+	if err := ms.UpdateLastEvent(&core.Event{Id: "missing", Version: 2}, ctx); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestStoredEventsAreCopies(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	ctx := context.Background()
+
+	e := &core.Event{Id: "id", Version: 1, Payload: []byte("a")}
+	if err := ms.AppendLastEvent(e, ctx); err != nil {
+		t.Fatal(err)
+	}
+	e.Payload[0] = 'b'
+
+	last, _ := ms.GetLastEvent("id", ctx)
+	if string(last.Payload) != "a" {
+		t.Errorf("expected stored payload to be unchanged, got %s", last.Payload)
+	}
+}
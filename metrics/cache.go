@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterCache registers the counters of an aggregate cache, labelled with
+// the name of the cache, so that several caches can share a registry.
+// It accepts the options of New.
+func RegisterCache(cache *eventstore.AggregateCache, name string, opts ...Option) error {
+	o := options{
+		registerer: prometheus.DefaultRegisterer,
+		namespace:  "eventstore",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	labels := prometheus.Labels{"cache": name}
+	counter := func(metric string, help string, value func(eventstore.CacheStats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Name:        metric,
+			Help:        help,
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(value(cache.Stats()))
+		})
+	}
+
+	collectors := []prometheus.Collector{
+		counter("cache_hits_total", "Number of aggregates loaded from the cache.",
+			func(s eventstore.CacheStats) uint64 { return s.Hits }),
+		counter("cache_misses_total", "Number of aggregates not found in the cache.",
+			func(s eventstore.CacheStats) uint64 { return s.Misses }),
+		counter("cache_evictions_total", "Number of aggregates evicted from the cache to make room for others.",
+			func(s eventstore.CacheStats) uint64 { return s.Evictions }),
+		counter("cache_invalidations_total", "Number of aggregates removed from the cache by an invalidation.",
+			func(s eventstore.CacheStats) uint64 { return s.Invalidations }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   o.namespace,
+			Name:        "cache_size",
+			Help:        "Number of aggregates in the cache.",
+			ConstLabels: labels,
+		}, func() float64 {
+			return float64(cache.Stats().Size)
+		}),
+	}
+	for _, c := range collectors {
+		if err := o.registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// Package metrics reports the activity of an event store to Prometheus.
+//
+// New registers the collectors with a registry. NewStore wraps an event store
+// to measure its publishes and loads, and Retryer wraps the retryer of a
+// DynamoDB client to count its throttled requests and retries:
+//
+//	m, err := metrics.New(metrics.WithRegisterer(registry))
+//	dbc, err := dynamodbstore.NewClient(c, dynamodbstore.WithRetryer(m.Retryer))
+//	store := metrics.NewStore(eventstore.NewEventStore(dbc, dbc, timer), m)
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The operations of the event store, used as the operation label.
+const (
+	OperationPublish      = "publish"
+	OperationPublishBatch = "publish_batch"
+	OperationLoad         = "load"
+	OperationLoadNew      = "load_new"
+)
+
+// Metrics are the collectors of the event store metrics.
+type Metrics struct {
+	duration       *prometheus.HistogramVec
+	errors         *prometheus.CounterVec
+	publishedEvent *prometheus.CounterVec
+	conflicts      *prometheus.CounterVec
+	eventsPerLoad  *prometheus.HistogramVec
+	payloadSize    *prometheus.HistogramVec
+	throttles      prometheus.Counter
+	retries        prometheus.Counter
+}
+
+type options struct {
+	registerer prometheus.Registerer
+	namespace  string
+}
+
+type Option func(*options)
+
+// WithRegisterer sets the registry of the collectors, the default Prometheus
+// registry by default.
+func WithRegisterer(r prometheus.Registerer) Option {
+	return func(o *options) {
+		o.registerer = r
+	}
+}
+
+// WithNamespace sets the prefix of the metric names, "eventstore" by default.
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// New creates the collectors and registers them.
+// It returns an error if a collector cannot be registered, for example because
+// the registry already has metrics with the same names.
+func New(opts ...Option) (*Metrics, error) {
+	o := options{
+		registerer: prometheus.DefaultRegisterer,
+		namespace:  "eventstore",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	m := &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of the publishes and loads, by operation and entity.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation", "entity"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "operation_errors_total",
+			Help:      "Number of failed publishes and loads, by operation and entity.",
+		}, []string{"operation", "entity"}),
+		publishedEvent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "published_events_total",
+			Help:      "Number of published events, by entity.",
+		}, []string{"entity"}),
+		conflicts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "version_conflicts_total",
+			Help:      "Number of publishes rejected because the stream has another version, by entity.",
+		}, []string{"entity"}),
+		eventsPerLoad: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "events_per_load",
+			Help:      "Number of events returned by a load, by entity.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}, []string{"entity"}),
+		payloadSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: o.namespace,
+			Name:      "payload_size_bytes",
+			Help:      "Size of the payloads of published events, by entity.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 8),
+		}, []string{"entity"}),
+		throttles: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "dynamodb_throttles_total",
+			Help:      "Number of DynamoDB requests that were throttled.",
+		}),
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: o.namespace,
+			Name:      "dynamodb_retries_total",
+			Help:      "Number of DynamoDB requests that were retried.",
+		}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.duration, m.errors, m.publishedEvent, m.conflicts,
+		m.eventsPerLoad, m.payloadSize, m.throttles, m.retries,
+	} {
+		if err := o.registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,170 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+	"github.com/akkgr/eventstore/metrics"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newMetrics(t *testing.T) (*metrics.Metrics, *prometheus.Registry) {
+	registry := prometheus.NewRegistry()
+	m, err := metrics.New(metrics.WithRegisterer(registry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, registry
+}
+
+func newEvent(t *testing.T, version int) *core.Event {
+	e, err := core.NewEvent("id", version, "Customer", "CustomerUpdated", map[string]string{"Name": "John Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// value returns the value of the counter, or the sample count of the
+// histogram, name with labels.
+func value(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					continue metrics
+				}
+			}
+			if m.GetHistogram() != nil {
+				return float64(m.GetHistogram().GetSampleCount())
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+func TestStore(t *testing.T) {
+	m, registry := newMetrics(t)
+	ms := memorystore.NewMemoryStore()
+	store := metrics.NewStore(eventstore.NewEventStore(ms, ms, core.NewDefaultTimer()), m)
+	ctx := context.Background()
+
+	if err := store.Publish(newEvent(t, 1), ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PublishBatch([]*core.Event{newEvent(t, 2), newEvent(t, 3)}, 1, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Publish(newEvent(t, 3), ctx); !errors.Is(err, core.ConcurrencyError{}) {
+		t.Fatalf("expected a concurrency error, got %v", err)
+	}
+	if _, err := store.LoadEvents("id", 0, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LoadEvents("missing", 0, ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := store.LoadNewEvents("id", 2, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	customer := map[string]string{"entity": "Customer"}
+	for _, tc := range []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"eventstore_published_events_total", customer, 3},
+		{"eventstore_version_conflicts_total", customer, 1},
+		{"eventstore_payload_size_bytes", customer, 3},
+		{"eventstore_events_per_load", customer, 2},
+		{"eventstore_operation_duration_seconds", map[string]string{"operation": "publish", "entity": "Customer"}, 2},
+		{"eventstore_operation_duration_seconds", map[string]string{"operation": "publish_batch", "entity": "Customer"}, 1},
+		{"eventstore_operation_duration_seconds", map[string]string{"operation": "load", "entity": "Customer"}, 1},
+		{"eventstore_operation_duration_seconds", map[string]string{"operation": "load_new", "entity": "Customer"}, 1},
+		{"eventstore_operation_errors_total", map[string]string{"operation": "publish", "entity": "Customer"}, 1},
+		{"eventstore_operation_errors_total", map[string]string{"operation": "load", "entity": ""}, 1},
+	} {
+		if got := value(t, registry, tc.name, tc.labels); got != tc.want {
+			t.Errorf("expected %s%v to be %v, got %v", tc.name, tc.labels, tc.want, got)
+		}
+	}
+}
+
+func TestRetryer(t *testing.T) {
+	m, registry := newMetrics(t)
+	r := m.Retryer(retry.NewStandard())
+
+	throttled := &smithy.GenericAPIError{Code: "ProvisionedThroughputExceededException"}
+	if !r.IsErrorRetryable(throttled) {
+		t.Fatal("expected a throttled request to be retryable")
+	}
+	if _, err := r.RetryDelay(1, throttled); err != nil {
+		t.Fatal(err)
+	}
+	if r.IsErrorRetryable(&smithy.GenericAPIError{Code: "ValidationException"}) {
+		t.Fatal("expected an invalid request not to be retryable")
+	}
+
+	if got := value(t, registry, "eventstore_dynamodb_throttles_total", nil); got != 1 {
+		t.Errorf("expected 1 throttle, got %v", got)
+	}
+	if got := value(t, registry, "eventstore_dynamodb_retries_total", nil); got != 1 {
+		t.Errorf("expected 1 retry, got %v", got)
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	_, registry := newMetrics(t)
+	if _, err := metrics.New(metrics.WithRegisterer(registry)); err == nil {
+		t.Error("expected the metrics to be registered once")
+	}
+	if _, err := metrics.New(metrics.WithRegisterer(registry), metrics.WithNamespace("other")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRegisterCache(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	cache := eventstore.NewAggregateCache(10)
+	if err := metrics.RegisterCache(cache, "customers", metrics.WithRegisterer(registry)); err != nil {
+		t.Fatal(err)
+	}
+
+	ms := memorystore.NewMemoryStore()
+	repo := eventstore.NewRepository(eventstore.NewEventStore(ms, ms, core.NewDefaultTimer()), func() *customer.Customer {
+		return &customer.Customer{}
+	}, eventstore.WithCache(cache))
+	ctx := context.Background()
+
+	if err := repo.Save(repo.New(), []*core.Event{newEvent(t, 1)}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"id", "missing"} {
+		repo.Load(id, ctx)
+	}
+
+	labels := map[string]string{"cache": "customers"}
+	if got := value(t, registry, "eventstore_cache_hits_total", labels); got != 1 {
+		t.Errorf("expected 1 hit, got %v", got)
+	}
+	if got := value(t, registry, "eventstore_cache_misses_total", labels); got != 1 {
+		t.Errorf("expected 1 miss, got %v", got)
+	}
+}
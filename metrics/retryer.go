@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+)
+
+// Retryer wraps the retryer of a DynamoDB client to count the throttled
+// requests and the retries. It is meant for dynamodbstore.WithRetryer.
+func (m *Metrics) Retryer(r aws.Retryer) aws.Retryer {
+	return &retryer{Retryer: r, metrics: m}
+}
+
+type retryer struct {
+	aws.Retryer
+	metrics *Metrics
+}
+
+// IsErrorRetryable is called once for every failed attempt.
+func (r *retryer) IsErrorRetryable(err error) bool {
+	if retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err) == aws.TrueTernary {
+		r.metrics.throttles.Inc()
+	}
+	return r.Retryer.IsErrorRetryable(err)
+}
+
+// RetryDelay is called once for every retried attempt.
+func (r *retryer) RetryDelay(attempt int, err error) (time.Duration, error) {
+	d, err := r.Retryer.RetryDelay(attempt, err)
+	if err == nil {
+		r.metrics.retries.Inc()
+	}
+	return d, err
+}
+
+// GetAttemptToken implements aws.RetryerV2, so that the retryer it wraps keeps
+// receiving the context of the attempts.
+func (r *retryer) GetAttemptToken(c context.Context) (func(error) error, error) {
+	if r2, ok := r.Retryer.(aws.RetryerV2); ok {
+		return r2.GetAttemptToken(c)
+	}
+	return r.Retryer.GetInitialToken(), nil
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+)
+
+// EventStore is the interface of the event stores measured by Store, such as
+// *eventstore.EventStore.
+type EventStore interface {
+	eventstore.EventPublisher
+	eventstore.BatchPublisher
+	eventstore.EventLoader
+}
+
+// Store measures the publishes and loads of an event store. It implements
+// eventstore.Store and eventstore.NewEventsLoader, so that a Repository can use
+// it. The entity of a failed load, or of one that returns no events, is unknown
+// and reported as empty.
+type Store struct {
+	store   EventStore
+	metrics *Metrics
+}
+
+func NewStore(s EventStore, m *Metrics) *Store {
+	return &Store{store: s, metrics: m}
+}
+
+func (s *Store) Publish(e *core.Event, c context.Context) error {
+	start := time.Now()
+	err := s.store.Publish(e, c)
+	s.metrics.published(OperationPublish, e.Entity, []*core.Event{e}, time.Since(start), err)
+	return err
+}
+
+func (s *Store) PublishBatch(events []*core.Event, expectedVersion int, c context.Context) error {
+	if len(events) == 0 {
+		return s.store.PublishBatch(events, expectedVersion, c)
+	}
+
+	start := time.Now()
+	err := s.store.PublishBatch(events, expectedVersion, c)
+	s.metrics.published(OperationPublishBatch, events[0].Entity, events, time.Since(start), err)
+	return err
+}
+
+func (s *Store) LoadEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	start := time.Now()
+	events, err := s.store.LoadEvents(id, v, c)
+	s.metrics.loaded(OperationLoad, events, time.Since(start), err)
+	return events, err
+}
+
+// LoadNewEvents calls the LoadNewEvents of the event store it wraps, or its
+// LoadEvents if it is not an eventstore.NewEventsLoader.
+func (s *Store) LoadNewEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	nl, ok := s.store.(eventstore.NewEventsLoader)
+	if !ok {
+		return s.LoadEvents(id, v, c)
+	}
+
+	start := time.Now()
+	events, err := nl.LoadNewEvents(id, v, c)
+	s.metrics.loaded(OperationLoadNew, events, time.Since(start), err)
+	return events, err
+}
+
+// loaded records a load of events that took elapsed and failed with err, if
+// not nil.
+func (m *Metrics) loaded(operation string, events *[]core.Event, elapsed time.Duration, err error) {
+	entity := ""
+	if err == nil && events != nil && len(*events) > 0 {
+		entity = (*events)[len(*events)-1].Entity
+	}
+	m.duration.WithLabelValues(operation, entity).Observe(elapsed.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(operation, entity).Inc()
+		return
+	}
+	if events != nil {
+		m.eventsPerLoad.WithLabelValues(entity).Observe(float64(len(*events)))
+	}
+}
+
+// published records a publish of events of entity that took elapsed and failed
+// with err, if not nil.
+func (m *Metrics) published(operation string, entity string, events []*core.Event, elapsed time.Duration, err error) {
+	m.duration.WithLabelValues(operation, entity).Observe(elapsed.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(operation, entity).Inc()
+		if errors.Is(err, core.ConcurrencyError{}) {
+			m.conflicts.WithLabelValues(entity).Inc()
+		}
+		return
+	}
+
+	m.publishedEvent.WithLabelValues(entity).Add(float64(len(events)))
+	size := m.payloadSize.WithLabelValues(entity)
+	for _, e := range events {
+		size.Observe(float64(len(e.Payload)))
+	}
+}
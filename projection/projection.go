@@ -0,0 +1,115 @@
+// Package projection builds read models from the events of an event store.
+package projection
+
+import (
+	"context"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/subscription"
+)
+
+// Projection builds a read model from the events with the actions it handles.
+type Projection interface {
+	// Name identifies the projection and its checkpoint.
+	Name() string
+	// Actions returns the actions of the events the projection handles.
+	Actions() []string
+	// Project updates the read model with an event. It must be idempotent, as
+	// an event is projected again if the processor stops before saving its version.
+	Project(e *core.Event, c context.Context) error
+	// Reset removes the read model before it is rebuilt from zero.
+	Reset(c context.Context) error
+}
+
+// CheckpointStore stores the progress of projections: the position they have
+// read up to and the last version of every aggregate they have processed.
+// GetProjectedVersion returns 0 for an aggregate the projection has not processed.
+type CheckpointStore interface {
+	subscription.CheckpointStore
+	GetProjectedVersion(name string, id string, c context.Context) (int, error)
+	SaveProjectedVersion(name string, id string, version int, c context.Context) error
+	ClearProjectedVersions(name string, c context.Context) error
+}
+
+// Processor delivers events to a projection. It skips the versions of an
+// aggregate it has already projected, so events delivered again by a
+// subscription or a replay are not projected again. An event is projected and
+// its version saved in two writes, so processing is at least once: an event is
+// projected again if the processor stops between them.
+type Processor struct {
+	projection  Projection
+	checkpoints CheckpointStore
+	actions     map[string]bool
+}
+
+func NewProcessor(p Projection, cs CheckpointStore) *Processor {
+	actions := make(map[string]bool)
+	for _, action := range p.Actions() {
+		actions[action] = true
+	}
+	return &Processor{
+		projection:  p,
+		checkpoints: cs,
+		actions:     actions,
+	}
+}
+
+// Handle projects an event, unless the projection does not handle its action
+// or has already projected its version of the aggregate. The versions of the
+// events whose action is not handled are not saved.
+func (p *Processor) Handle(e *core.Event, c context.Context) error {
+	if !p.actions[e.Action] {
+		return nil
+	}
+
+	name := p.projection.Name()
+	v, err := p.checkpoints.GetProjectedVersion(name, e.Id, c)
+	if err != nil {
+		return err
+	}
+	if e.Version <= v {
+		return nil
+	}
+
+	if err := p.projection.Project(e, c); err != nil {
+		return err
+	}
+	return p.checkpoints.SaveProjectedVersion(name, e.Id, e.Version, c)
+}
+
+// Subscribe returns a subscription that delivers the events of source to the
+// projection.
+func (p *Processor) Subscribe(source subscription.Source, opts ...subscription.Option) *subscription.Subscription {
+	sub := subscription.NewSubscription(p.projection.Name(), source, p.checkpoints, opts...)
+	sub.Register(p)
+	return sub
+}
+
+// Rebuild resets the projection and replays the events of source from the
+// start until source is exhausted, e.g. a subscription.ReplaySource that
+// reads all stored events. The checkpoint of the subscription is kept: once
+// resumed, it delivers again the events committed since, and the events the
+// rebuild has already projected are skipped.
+func (p *Processor) Rebuild(source subscription.FiniteSource, c context.Context) error {
+	if err := p.projection.Reset(c); err != nil {
+		return err
+	}
+	if err := p.checkpoints.ClearProjectedVersions(p.projection.Name(), c); err != nil {
+		return err
+	}
+
+	position := ""
+	for !source.Exhausted(position) {
+		events, next, err := source.Read(position, c)
+		if err != nil {
+			return err
+		}
+		for i := range events {
+			if err := p.Handle(&events[i], c); err != nil {
+				return err
+			}
+		}
+		position = next
+	}
+	return nil
+}
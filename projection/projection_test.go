@@ -0,0 +1,130 @@
+package projection_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+	"github.com/akkgr/eventstore/projection"
+	"github.com/akkgr/eventstore/subscription"
+)
+
+// customerNames projects the names of created customers
+type customerNames struct {
+	names     map[string]string
+	projected int
+}
+
+func (p *customerNames) Name() string {
+	return "customer-names"
+}
+
+func (p *customerNames) Actions() []string {
+	return []string{customer.CustomerCreated}
+}
+
+func (p *customerNames) Project(e *core.Event, c context.Context) error {
+	var payload customer.CustomerCreatedEvent
+	if err := json.Unmarshal(e.Payload, &payload); err != nil {
+		return err
+	}
+	p.names[e.Id] = payload.Name
+	p.projected++
+	return nil
+}
+
+func (p *customerNames) Reset(c context.Context) error {
+	p.names = make(map[string]string)
+	return nil
+}
+
+func createCustomer(t *testing.T, es *eventstore.EventStore, id string, name string) {
+	t.Helper()
+	created, _ := core.NewEvent(id, 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: name})
+	updated, _ := core.NewEvent(id, 2, "Customer", customer.CustomerUpdated, customer.CustomerUpdatedEvent{Name: name})
+	if err := es.PublishBatch([]*core.Event{created, updated}, 0, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessorProjectsHandledActions(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	ctx := context.Background()
+
+	createCustomer(t, es, "a", "John Doe")
+	createCustomer(t, es, "b", "Jane Doe")
+
+	p := &customerNames{names: make(map[string]string)}
+	sub := projection.NewProcessor(p, ms).Subscribe(ms)
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.names["a"] != "John Doe" || p.names["b"] != "Jane Doe" || p.projected != 2 {
+		t.Errorf("unexpected read model %v", p.names)
+	}
+	// the version of the update, which the projection ignores, is not saved
+	if v, _ := ms.GetProjectedVersion(p.Name(), "a", ctx); v != 1 {
+		t.Errorf("expected version 1 to be projected, got %d", v)
+	}
+}
+
+func TestProcessorSkipsProcessedVersions(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	ctx := context.Background()
+
+	p := &customerNames{names: make(map[string]string)}
+	processor := projection.NewProcessor(p, ms)
+
+	e, _ := core.NewEvent("a", 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John Doe"})
+	for i := 0; i < 3; i++ {
+		if err := processor.Handle(e, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if p.projected != 1 {
+		t.Errorf("expected the event to be projected once, got %d", p.projected)
+	}
+}
+
+func TestProcessorRebuild(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	ctx := context.Background()
+
+	createCustomer(t, es, "a", "John Doe")
+
+	p := &customerNames{names: make(map[string]string)}
+	processor := projection.NewProcessor(p, ms)
+	if _, err := processor.Subscribe(ms).Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// lose the read model and rebuild it
+	p.names = map[string]string{}
+	createCustomer(t, es, "b", "Jane Doe")
+	if err := processor.Rebuild(subscription.NewReplaySource(ms, es), ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.names["a"] != "John Doe" || p.names["b"] != "Jane Doe" {
+		t.Errorf("unexpected read model %v", p.names)
+	}
+	if p.projected != 3 {
+		t.Errorf("expected 3 projected events, got %d", p.projected)
+	}
+
+	// the subscription resumes from its checkpoint and skips the rebuilt events
+	if _, err := processor.Subscribe(ms).Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p.projected != 3 {
+		t.Errorf("expected the rebuilt events to be skipped, got %d projected events", p.projected)
+	}
+}
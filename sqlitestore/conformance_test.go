@@ -0,0 +1,26 @@
+package sqlitestore_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/akkgr/eventstore/eventstoretest"
+	"github.com/akkgr/eventstore/sqlitestore"
+)
+
+func TestConformance(t *testing.T) {
+	s, err := sqlitestore.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	if err := sqlitestore.CreateTables(s, context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	eventstoretest.Run(t, func(t *testing.T) eventstoretest.Backend {
+		return s
+	})
+}
@@ -0,0 +1,28 @@
+package sqlitestore
+
+import "context"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS events (
+	position       INTEGER PRIMARY KEY AUTOINCREMENT,
+	id             TEXT    NOT NULL,
+	version        INTEGER NOT NULL,
+	entity         TEXT    NOT NULL,
+	action         TEXT    NOT NULL,
+	created        TEXT    NOT NULL,
+	payload        BLOB,
+	schema_version INTEGER NOT NULL DEFAULT 0,
+	correlation_id TEXT    NOT NULL DEFAULT '',
+	causation_id   TEXT    NOT NULL DEFAULT '',
+	actor          TEXT    NOT NULL DEFAULT '',
+	metadata       TEXT,
+	UNIQUE (id, version)
+);
+CREATE INDEX IF NOT EXISTS events_entity ON events (entity, position);
+`
+
+// CreateTables creates the events table and its indexes, unless they exist.
+func CreateTables(s *SQLiteStore, c context.Context) error {
+	_, err := s.db.ExecContext(c, schema)
+	return err
+}
@@ -0,0 +1,265 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// readLimit is the maximum number of events returned by a single Read or ReadByEntity.
+const readLimit = 100
+
+const columns = `id, version, entity, action, created, payload, schema_version, correlation_id, causation_id, actor, metadata`
+
+// SQLiteStore stores events in a single SQLite table, with one row per event and
+// a unique (id, version) constraint. The last event of an aggregate is the row
+// with its highest version.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore returns a store that uses an open database. Transactions of db
+// must take the write lock when they begin, see Open.
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+// Open opens the SQLite database file at path, creating it if needed.
+func Open(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+
+		"?_txlock=immediate&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	return NewSQLiteStore(db), nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// AppendLastEvent stores the first event of the aggregate, but only if the
+// aggregate has no events.
+func (s *SQLiteStore) AppendLastEvent(e *core.Event, c context.Context) error {
+	return s.append(0, []*core.Event{e}, c)
+}
+
+// UpdateLastEvent stores e, but only if the last event of the aggregate has the
+// version preceding e.
+func (s *SQLiteStore) UpdateLastEvent(e *core.Event, c context.Context) error {
+	return s.append(e.Version-1, []*core.Event{e}, c)
+}
+
+// AppendEvent does nothing: the last event of an aggregate stays in the events
+// table when it is followed by another event.
+func (s *SQLiteStore) AppendEvent(e *core.Event, c context.Context) error {
+	return nil
+}
+
+// TransactLastEvent stores e, but only if the last event of the aggregate still
+// has the version of last.
+func (s *SQLiteStore) TransactLastEvent(last *core.Event, e *core.Event, c context.Context) error {
+	return s.append(last.Version, []*core.Event{e}, c)
+}
+
+// AppendEvents stores events in a single transaction, but only if the last event
+// of the aggregate still has the version of last, or if it has no events when
+// last is nil.
+func (s *SQLiteStore) AppendEvents(last *core.Event, events []*core.Event, c context.Context) error {
+	expected := 0
+	if last != nil {
+		expected = last.Version
+	}
+	return s.append(expected, events, c)
+}
+
+// append stores events if the aggregate is at the expected version.
+func (s *SQLiteStore) append(expected int, events []*core.Event, c context.Context) error {
+	if len(events) == 0 {
+		return nil
+	}
+	id := events[0].Id
+
+	tx, err := s.db.BeginTx(c, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var actual int
+	err = tx.QueryRowContext(c, `SELECT COALESCE(MAX(version), 0) FROM events WHERE id = ?`, id).Scan(&actual)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return core.ConcurrencyError{StreamId: id, Expected: expected, Actual: actual}
+	}
+
+	for _, e := range events {
+		metadata, err := marshalMetadata(e.Metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(c, `INSERT INTO events (`+columns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.Id, e.Version, e.Entity, e.Action, e.Created.UTC().Format(time.RFC3339Nano), e.Payload,
+			e.SchemaVersion, e.CorrelationId, e.CausationId, e.Actor, metadata)
+		if isConstraintError(err) {
+			return core.ConcurrencyError{StreamId: id, Expected: expected, Actual: actual}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteStore) GetLastEvent(id string, c context.Context) (*core.Event, error) {
+	rows, err := s.db.QueryContext(c, `SELECT `+columns+` FROM events WHERE id = ? ORDER BY version DESC LIMIT 1`, id)
+	if err != nil {
+		return &core.Event{}, err
+	}
+
+	events, err := scanEvents(rows)
+	if err != nil || len(events) == 0 {
+		return &core.Event{}, err
+	}
+	return &events[0], nil
+}
+
+func (s *SQLiteStore) GetEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	rows, err := s.db.QueryContext(c, `SELECT `+columns+` FROM events
+		WHERE id = ? AND version > ? AND version < (SELECT MAX(version) FROM events WHERE id = ?)
+		ORDER BY version`, id, v, id)
+	if err != nil {
+		return &[]core.Event{}, err
+	}
+
+	events, err := scanEvents(rows)
+	return &events, err
+}
+
+// ReadByEntity returns the events of entity stored after position, in the order
+// they were stored. A position is the row position of the last returned event.
+func (s *SQLiteStore) ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error) {
+	from, err := parsePosition(position)
+	if err != nil {
+		return nil, position, err
+	}
+
+	rows, err := s.db.QueryContext(c, `SELECT position, `+columns+` FROM events
+		WHERE entity = ? AND position > ? ORDER BY position LIMIT ?`, entity, from, readLimit)
+	if err != nil {
+		return nil, position, err
+	}
+
+	events, last, err := scanPositionedEvents(rows)
+	if err != nil {
+		return nil, position, err
+	}
+	if len(events) == 0 {
+		return &events, position, nil
+	}
+	return &events, strconv.FormatInt(last, 10), nil
+}
+
+// Read returns the events stored after position, in the order they were stored.
+// A position is the row position of the last returned event.
+func (s *SQLiteStore) Read(position string, c context.Context) ([]core.Event, string, error) {
+	from, err := parsePosition(position)
+	if err != nil {
+		return nil, position, err
+	}
+
+	rows, err := s.db.QueryContext(c, `SELECT position, `+columns+` FROM events
+		WHERE position > ? ORDER BY position LIMIT ?`, from, readLimit)
+	if err != nil {
+		return nil, position, err
+	}
+
+	events, last, err := scanPositionedEvents(rows)
+	if err != nil || len(events) == 0 {
+		return nil, position, err
+	}
+	return events, strconv.FormatInt(last, 10), nil
+}
+
+func parsePosition(position string) (int64, error) {
+	if position == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(position, 10, 64)
+}
+
+// scanner is implemented by *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEvent(row scanner, dest ...any) (core.Event, error) {
+	e := core.Event{}
+	var created string
+	var metadata sql.NullString
+	err := row.Scan(append(dest, &e.Id, &e.Version, &e.Entity, &e.Action, &created, &e.Payload,
+		&e.SchemaVersion, &e.CorrelationId, &e.CausationId, &e.Actor, &metadata)...)
+	if err != nil {
+		return e, err
+	}
+	if e.Created, err = time.Parse(time.RFC3339Nano, created); err != nil {
+		return e, err
+	}
+	if metadata.Valid {
+		err = json.Unmarshal([]byte(metadata.String), &e.Metadata)
+	}
+	return e, err
+}
+
+func scanEvents(rows *sql.Rows) ([]core.Event, error) {
+	defer rows.Close()
+	events := []core.Event{}
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// scanPositionedEvents scans events and returns the position of the last one.
+func scanPositionedEvents(rows *sql.Rows) ([]core.Event, int64, error) {
+	defer rows.Close()
+	events := []core.Event{}
+	var position int64
+	for rows.Next() {
+		e, err := scanEvent(rows, &position)
+		if err != nil {
+			return nil, 0, err
+		}
+		events = append(events, e)
+	}
+	return events, position, rows.Err()
+}
+
+func marshalMetadata(metadata map[string]string) (sql.NullString, error) {
+	if len(metadata) == 0 {
+		return sql.NullString{}, nil
+	}
+	b, err := json.Marshal(metadata)
+	return sql.NullString{String: string(b), Valid: true}, err
+}
+
+// isConstraintError reports whether err is the violation of the unique
+// (id, version) constraint.
+func isConstraintError(err error) bool {
+	var se *sqlite.Error
+	return errors.As(err, &se) && se.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
@@ -0,0 +1,119 @@
+package subscription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+)
+
+// replayBatchSize is the number of events after which a ReplaySource stops
+// loading streams in a single Read.
+const replayBatchSize = 100
+
+// replayPosition is the position of a ReplaySource, encoded as JSON.
+type replayPosition struct {
+	// Streams is the position of the page of stream ids being replayed.
+	Streams string `json:"streams,omitempty"`
+	// Next is the index of the next stream to replay in the page.
+	Next int `json:"next,omitempty"`
+	// Done is true once all streams have been replayed.
+	Done bool `json:"done,omitempty"`
+}
+
+type streamPage struct {
+	position string
+	ids      []string
+	next     string
+}
+
+// ReplaySource reads the events stored in an event store, stream after stream,
+// rather than the events committed after a position. Unlike a source that
+// reads a change log, it returns all events, however old they are, but only
+// those stored when their stream is read. It is a FiniteSource, meant to
+// rebuild read models.
+// Events are returned in version order per aggregate, with no order between
+// aggregates.
+// A ReplaySource is not safe for concurrent use.
+type ReplaySource struct {
+	lister eventstore.StreamLister
+	loader eventstore.EventLoader
+	page   *streamPage
+}
+
+// NewReplaySource returns a source that lists the streams with lister and loads
+// their events with loader, e.g. an *eventstore.EventStore, which also upcasts them.
+func NewReplaySource(lister eventstore.StreamLister, loader eventstore.EventLoader) *ReplaySource {
+	return &ReplaySource{lister: lister, loader: loader}
+}
+
+// Read returns the events of the streams after position, stopping after the
+// stream that makes them reach a batch or at the end of a page of stream ids.
+// It returns no events, but a new position, for an empty page of stream ids.
+func (s *ReplaySource) Read(position string, c context.Context) ([]core.Event, string, error) {
+	pos, err := decodeReplayPosition(position)
+	if err != nil {
+		return nil, position, err
+	}
+	if pos.Done {
+		return nil, position, nil
+	}
+
+	page, err := s.listStreams(pos.Streams, c)
+	if err != nil {
+		return nil, position, err
+	}
+
+	var events []core.Event
+	for pos.Next < len(page.ids) && len(events) < replayBatchSize {
+		stream, err := s.loader.LoadEvents(page.ids[pos.Next], 0, c)
+		// a stream listed without events has nothing to replay
+		if err != nil && !errors.As(err, &core.EventsNotFound{}) {
+			return nil, position, err
+		}
+		if err == nil {
+			events = append(events, *stream...)
+		}
+		pos.Next++
+	}
+	if pos.Next >= len(page.ids) {
+		pos = replayPosition{Streams: page.next, Done: page.next == ""}
+	}
+
+	encoded, err := json.Marshal(pos)
+	if err != nil {
+		return nil, position, err
+	}
+	return events, string(encoded), nil
+}
+
+// Exhausted reports whether all streams have been replayed at position.
+func (s *ReplaySource) Exhausted(position string) bool {
+	pos, err := decodeReplayPosition(position)
+	return err == nil && pos.Done
+}
+
+// listStreams returns the page of stream ids at position, keeping the last
+// page so that it is listed once for all of its streams.
+func (s *ReplaySource) listStreams(position string, c context.Context) (*streamPage, error) {
+	if s.page != nil && s.page.position == position {
+		return s.page, nil
+	}
+	ids, next, err := s.lister.ListStreams(position, c)
+	if err != nil {
+		return nil, err
+	}
+	s.page = &streamPage{position: position, ids: ids, next: next}
+	return s.page, nil
+}
+
+func decodeReplayPosition(position string) (replayPosition, error) {
+	pos := replayPosition{}
+	if position == "" {
+		return pos, nil
+	}
+	err := json.Unmarshal([]byte(position), &pos)
+	return pos, err
+}
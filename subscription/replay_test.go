@@ -0,0 +1,69 @@
+package subscription_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+	"github.com/akkgr/eventstore/subscription"
+)
+
+// pagedLister lists fixed pages of stream ids, like a DynamoDB Scan that can
+// return empty pages before the end of the table
+type pagedLister struct {
+	pages [][]string
+}
+
+func (l *pagedLister) ListStreams(position string, c context.Context) ([]string, string, error) {
+	page := 0
+	if position != "" {
+		page, _ = strconv.Atoi(position)
+	}
+	next := ""
+	if page+1 < len(l.pages) {
+		next = strconv.Itoa(page + 1)
+	}
+	return l.pages[page], next, nil
+}
+
+func TestReplaySource(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	ctx := context.Background()
+
+	publish(t, es, "a", 1, 2)
+	publish(t, es, "b", 1, 1)
+
+	source := subscription.NewReplaySource(&pagedLister{pages: [][]string{{}, {"a"}, {}, {"missing", "b"}}}, es)
+
+	var replayed []string
+	position := ""
+	reads := 0
+	for !source.Exhausted(position) {
+		events, next, err := source.Read(position, ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range events {
+			replayed = append(replayed, fmt.Sprintf("%s:%d", e.Id, e.Version))
+		}
+		position = next
+		reads++
+	}
+
+	if fmt.Sprint(replayed) != "[a:1 a:2 b:1]" {
+		t.Errorf("unexpected events %v", replayed)
+	}
+	if reads != 4 {
+		t.Errorf("expected a read per page, got %d", reads)
+	}
+
+	// an exhausted source returns no events
+	if events, next, err := source.Read(position, ctx); err != nil || len(events) != 0 || next != position {
+		t.Errorf("expected no events, got %v, %q, %v", events, next, err)
+	}
+}
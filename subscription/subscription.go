@@ -0,0 +1,154 @@
+// Package subscription delivers the events committed to an event store to
+// registered handlers, resuming from a persisted checkpoint.
+package subscription
+
+import (
+	"context"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+)
+
+// DefaultPollInterval is the time a subscription waits before reading again
+// from a source that had no new events.
+const DefaultPollInterval = time.Second
+
+// Handler handles the events delivered by a subscription.
+type Handler interface {
+	Handle(e *core.Event, c context.Context) error
+}
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc func(e *core.Event, c context.Context) error
+
+func (f HandlerFunc) Handle(e *core.Event, c context.Context) error {
+	return f(e, c)
+}
+
+// Source reads the events committed to an event store.
+// Read returns the events committed after position, in version order per
+// aggregate, and the position after the returned events. It returns no events,
+// rather than blocking, if none are available. The empty position is the
+// start of the source.
+type Source interface {
+	Read(position string, c context.Context) ([]core.Event, string, error)
+}
+
+// FiniteSource is implemented by sources whose events end, such as a replay of
+// the stored events. Exhausted reports whether no events are left after
+// position. A source may return no events before it is exhausted, so only
+// Exhausted tells that all events have been read.
+type FiniteSource interface {
+	Source
+	Exhausted(position string) bool
+}
+
+// HeadSource is implemented by sources that can return the position after the
+// events committed so far without reading them, for subscriptions that only
+// need the events committed from now on.
+type HeadSource interface {
+	Head(c context.Context) (string, error)
+}
+
+// CheckpointStore stores the position of every subscription.
+// GetCheckpoint returns the empty position if the subscription has none.
+type CheckpointStore interface {
+	GetCheckpoint(name string, c context.Context) (string, error)
+	SaveCheckpoint(name string, position string, c context.Context) error
+}
+
+type Option func(*Subscription)
+
+// WithPollInterval sets the time to wait for new events, DefaultPollInterval by default.
+func WithPollInterval(d time.Duration) Option {
+	return func(s *Subscription) {
+		s.interval = d
+	}
+}
+
+// Subscription reads events from a source and delivers them to its handlers.
+// Events are delivered at least once: the checkpoint is saved after all
+// handlers have handled the events read from the source.
+type Subscription struct {
+	name        string
+	source      Source
+	checkpoints CheckpointStore
+	handlers    []Handler
+	interval    time.Duration
+}
+
+// NewSubscription returns a subscription that stores its checkpoint under name.
+func NewSubscription(name string, s Source, cs CheckpointStore, opts ...Option) *Subscription {
+	sub := &Subscription{
+		name:        name,
+		source:      s,
+		checkpoints: cs,
+		interval:    DefaultPollInterval,
+	}
+	for _, opt := range opts {
+		opt(sub)
+	}
+	return sub
+}
+
+// Register adds a handler. Handlers are called in the order they were registered.
+func (s *Subscription) Register(h Handler) {
+	s.handlers = append(s.handlers, h)
+}
+
+// Poll reads the events after the checkpoint once, delivers them to the
+// handlers and saves the new checkpoint.
+// It returns the number of delivered events. If a handler fails, the
+// checkpoint is not saved and the events are delivered again by the next poll.
+func (s *Subscription) Poll(c context.Context) (int, error) {
+	position, err := s.checkpoints.GetCheckpoint(s.name, c)
+	if err != nil {
+		return 0, err
+	}
+
+	events, next, err := s.source.Read(position, c)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range events {
+		for _, h := range s.handlers {
+			if err := h.Handle(&events[i], c); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	if next != position {
+		if err := s.checkpoints.SaveCheckpoint(s.name, next, c); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// Run polls the source until the context is done or an error occurs.
+func (s *Subscription) Run(c context.Context) error {
+	for {
+		if err := c.Err(); err != nil {
+			return err
+		}
+
+		n, err := s.Poll(c)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+
+		t := time.NewTimer(s.interval)
+		select {
+		case <-c.Done():
+			t.Stop()
+			return c.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package subscription_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+	"github.com/akkgr/eventstore/subscription"
+)
+
+// recorder records the events it handles
+type recorder struct {
+	events []string
+	fail   bool
+}
+
+func (r *recorder) Handle(e *core.Event, c context.Context) error {
+	if r.fail {
+		return errors.New("some error")
+	}
+	r.events = append(r.events, fmt.Sprintf("%s:%d", e.Id, e.Version))
+	return nil
+}
+
+func publish(t *testing.T, es *eventstore.EventStore, id string, from int, to int) {
+	t.Helper()
+	for v := from; v <= to; v++ {
+		e, _ := core.NewEvent(id, v, "test", "test", v)
+		if err := es.Publish(e, context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPollDeliversEventsInOrder(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	ctx := context.Background()
+
+	publish(t, es, "a", 1, 2)
+	publish(t, es, "b", 1, 1)
+	if err := es.PublishBatch([]*core.Event{
+		{Id: "a", Version: 3}, {Id: "a", Version: 4},
+	}, 2, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &recorder{}
+	sub := subscription.NewSubscription("test", ms, ms)
+	sub.Register(r)
+
+	n, err := sub.Poll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 events, got %d", n)
+	}
+	if got := fmt.Sprint(r.events); got != "[a:1 a:2 b:1 a:3 a:4]" {
+		t.Errorf("unexpected events %s", got)
+	}
+}
+
+func TestPollResumesFromCheckpoint(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	ctx := context.Background()
+
+	publish(t, es, "a", 1, 2)
+	first := &recorder{}
+	sub := subscription.NewSubscription("test", ms, ms)
+	sub.Register(first)
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new subscription with the same name continues after the checkpoint
+	publish(t, es, "a", 3, 3)
+	second := &recorder{}
+	sub = subscription.NewSubscription("test", ms, ms)
+	sub.Register(second)
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := fmt.Sprint(second.events); got != "[a:3]" {
+		t.Errorf("unexpected events %s", got)
+	}
+}
+
+func TestPollHandlerFailure(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	ctx := context.Background()
+
+	publish(t, es, "a", 1, 2)
+	r := &recorder{fail: true}
+	sub := subscription.NewSubscription("test", ms, ms)
+	sub.Register(r)
+
+	if _, err := sub.Poll(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	// the events are delivered again once the handler succeeds
+	r.fail = false
+	if _, err := sub.Poll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(r.events); got != "[a:1 a:2]" {
+		t.Errorf("unexpected events %s", got)
+	}
+}
+
+func TestRunStopsWhenContextIsDone(t *testing.T) {
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(ms, ms, core.NewDefaultTimer())
+	publish(t, es, "a", 1, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	delivered := make(chan struct{})
+	sub := subscription.NewSubscription("test", ms, ms, subscription.WithPollInterval(time.Millisecond))
+	sub.Register(subscription.HandlerFunc(func(e *core.Event, c context.Context) error {
+		close(delivered)
+		return nil
+	}))
+
+	done := make(chan error)
+	go func() {
+		done <- sub.Run(ctx)
+	}()
+
+	<-delivered
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Reader traces the calls to an event store reader.
+// It implements eventstore.EntityReader and eventstore.StreamLister, and
+// returns core.NotSupported if the reader it wraps does not.
+type Reader struct {
+	reader eventstore.EventStoreReader
+	tracer trace.Tracer
+}
+
+func NewReader(r eventstore.EventStoreReader, opts ...Option) *Reader {
+	return &Reader{reader: r, tracer: newTracer(opts)}
+}
+
+func (r *Reader) GetLastEvent(id string, c context.Context) (*core.Event, error) {
+	c, s := start(r.tracer, c, "EventStoreReader.GetLastEvent", trace.SpanKindClient, StreamIdKey.String(id))
+	e, err := r.reader.GetLastEvent(id, c)
+	if err == nil {
+		s.SetAttributes(eventAttributes(e)...)
+	}
+	s.end(err)
+	return e, err
+}
+
+func (r *Reader) GetEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	c, s := start(r.tracer, c, "EventStoreReader.GetEvents", trace.SpanKindClient,
+		StreamIdKey.String(id), VersionKey.Int(v))
+	events, err := r.reader.GetEvents(id, v, c)
+	if err == nil && events != nil {
+		s.SetAttributes(EventCountKey.Int(len(*events)))
+	}
+	s.end(err)
+	return events, err
+}
+
+func (r *Reader) ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error) {
+	er, ok := r.reader.(eventstore.EntityReader)
+	if !ok {
+		return nil, position, core.NotSupported{Operation: "read by entity"}
+	}
+
+	c, s := start(r.tracer, c, "EventStoreReader.ReadByEntity", trace.SpanKindClient, EntityKey.String(entity))
+	events, next, err := er.ReadByEntity(entity, position, c)
+	if err == nil && events != nil {
+		s.SetAttributes(EventCountKey.Int(len(*events)))
+	}
+	s.end(err)
+	return events, next, err
+}
+
+func (r *Reader) ListStreams(position string, c context.Context) ([]string, string, error) {
+	sl, ok := r.reader.(eventstore.StreamLister)
+	if !ok {
+		return nil, position, core.NotSupported{Operation: "list streams"}
+	}
+
+	c, s := start(r.tracer, c, "EventStoreReader.ListStreams", trace.SpanKindClient)
+	ids, next, err := sl.ListStreams(position, c)
+	s.end(err)
+	return ids, next, err
+}
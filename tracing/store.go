@@ -0,0 +1,82 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventStore is the interface of the event stores traced by Store, such as
+// *eventstore.EventStore.
+type EventStore interface {
+	eventstore.EventPublisher
+	eventstore.BatchPublisher
+	eventstore.EventLoader
+}
+
+// Store traces the calls to an event store. It implements eventstore.Store and
+// eventstore.NewEventsLoader, so that a Repository can use it, and
+// eventstore.EntityReader, returning core.NotSupported if the event store it
+// wraps does not.
+type Store struct {
+	store  EventStore
+	tracer trace.Tracer
+}
+
+func NewStore(s EventStore, opts ...Option) *Store {
+	return &Store{store: s, tracer: newTracer(opts)}
+}
+
+func (st *Store) Publish(e *core.Event, c context.Context) error {
+	c, s := start(st.tracer, c, "EventStore.Publish", trace.SpanKindInternal, eventAttributes(e)...)
+	err := st.store.Publish(e, c)
+	s.end(err)
+	return err
+}
+
+func (st *Store) PublishBatch(events []*core.Event, expectedVersion int, c context.Context) error {
+	c, s := start(st.tracer, c, "EventStore.PublishBatch", trace.SpanKindInternal, batchAttributes(events, expectedVersion)...)
+	err := st.store.PublishBatch(events, expectedVersion, c)
+	s.end(err)
+	return err
+}
+
+func (st *Store) LoadEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	c, s := start(st.tracer, c, "EventStore.LoadEvents", trace.SpanKindInternal,
+		StreamIdKey.String(id), VersionKey.Int(v))
+	events, err := st.store.LoadEvents(id, v, c)
+	s.loaded(events, err)
+	return events, err
+}
+
+// LoadNewEvents calls the LoadNewEvents of the event store it wraps, or its
+// LoadEvents if it is not an eventstore.NewEventsLoader.
+func (st *Store) LoadNewEvents(id string, v int, c context.Context) (*[]core.Event, error) {
+	nl, ok := st.store.(eventstore.NewEventsLoader)
+	if !ok {
+		return st.LoadEvents(id, v, c)
+	}
+
+	c, s := start(st.tracer, c, "EventStore.LoadNewEvents", trace.SpanKindInternal,
+		StreamIdKey.String(id), VersionKey.Int(v))
+	events, err := nl.LoadNewEvents(id, v, c)
+	s.loaded(events, err)
+	return events, err
+}
+
+func (st *Store) ReadByEntity(entity string, position string, c context.Context) (*[]core.Event, string, error) {
+	er, ok := st.store.(eventstore.EntityReader)
+	if !ok {
+		return nil, position, core.NotSupported{Operation: "read by entity"}
+	}
+
+	c, s := start(st.tracer, c, "EventStore.ReadByEntity", trace.SpanKindInternal, EntityKey.String(entity))
+	events, next, err := er.ReadByEntity(entity, position, c)
+	if err == nil && events != nil {
+		s.SetAttributes(EventCountKey.Int(len(*events)))
+	}
+	s.end(err)
+	return events, next, err
+}
@@ -0,0 +1,112 @@
+// Package tracing traces the calls to an event store with OpenTelemetry.
+//
+// NewReader, NewWriter and NewStore wrap a reader, a writer and an event store
+// and start a span for every call, with the attributes below. The capacity
+// consumed by the DynamoDB requests made during a call is recorded with
+// dynamodbstore.WithConsumedCapacity.
+//
+//	es := eventstore.NewEventStore(tracing.NewReader(dbc), tracing.NewWriter(dbc), timer)
+//	store := tracing.NewStore(es)
+package tracing
+
+import (
+	"context"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/dynamodbstore"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/akkgr/eventstore/tracing"
+
+// The attributes of the spans.
+const (
+	StreamIdKey         = attribute.Key("eventstore.stream.id")
+	VersionKey          = attribute.Key("eventstore.version")
+	ExpectedVersionKey  = attribute.Key("eventstore.expected_version")
+	EntityKey           = attribute.Key("eventstore.entity")
+	ActionKey           = attribute.Key("eventstore.action")
+	EventCountKey       = attribute.Key("eventstore.event_count")
+	ConsumedCapacityKey = attribute.Key("aws.dynamodb.consumed_capacity_units")
+)
+
+type options struct {
+	provider trace.TracerProvider
+}
+
+type Option func(*options)
+
+// WithTracerProvider sets the provider of the tracer, the global provider by default.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.provider = tp
+	}
+}
+
+func newTracer(opts []Option) trace.Tracer {
+	o := options{provider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o.provider.Tracer(instrumentationName)
+}
+
+// span is a started span that records the capacity consumed within it.
+type span struct {
+	trace.Span
+	capacity *dynamodbstore.ConsumedCapacity
+}
+
+func start(t trace.Tracer, c context.Context, name string, kind trace.SpanKind, attrs ...attribute.KeyValue) (context.Context, span) {
+	c, s := t.Start(c, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	cc := &dynamodbstore.ConsumedCapacity{}
+	return dynamodbstore.WithConsumedCapacity(c, cc), span{Span: s, capacity: cc}
+}
+
+// end records err and the consumed capacity, and ends the span.
+// loaded records the events returned by a load that succeeded.
+func (s span) loaded(events *[]core.Event, err error) {
+	if err == nil && events != nil {
+		s.SetAttributes(EventCountKey.Int(len(*events)))
+		if n := len(*events); n > 0 {
+			s.SetAttributes(EntityKey.String((*events)[n-1].Entity))
+		}
+	}
+	s.end(err)
+}
+
+func (s span) end(err error) {
+	if units := s.capacity.Units(); units > 0 {
+		s.SetAttributes(ConsumedCapacityKey.Float64(units))
+	}
+	if err != nil {
+		s.RecordError(err)
+		s.SetStatus(codes.Error, err.Error())
+	}
+	s.End()
+}
+
+func eventAttributes(e *core.Event) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		StreamIdKey.String(e.Id),
+		VersionKey.Int(e.Version),
+		EntityKey.String(e.Entity),
+		ActionKey.String(e.Action),
+	}
+}
+
+// batchAttributes returns the attributes of events appended to a stream at expected.
+func batchAttributes(events []*core.Event, expected int) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		ExpectedVersionKey.Int(expected),
+		EventCountKey.Int(len(events)),
+	}
+	if len(events) > 0 {
+		last := events[len(events)-1]
+		attrs = append(attrs, StreamIdKey.String(last.Id), VersionKey.Int(last.Version), EntityKey.String(last.Entity))
+	}
+	return attrs
+}
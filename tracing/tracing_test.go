@@ -0,0 +1,163 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/customer"
+	"github.com/akkgr/eventstore/eventstore"
+	"github.com/akkgr/eventstore/memorystore"
+	"github.com/akkgr/eventstore/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newStore() (*tracing.Store, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	opt := tracing.WithTracerProvider(tp)
+
+	ms := memorystore.NewMemoryStore()
+	es := eventstore.NewEventStore(tracing.NewReader(ms, opt), tracing.NewWriter(ms, opt), core.NewDefaultTimer())
+	return tracing.NewStore(es, opt), exporter
+}
+
+func newEvent(t *testing.T, version int) *core.Event {
+	e, err := core.NewEvent("id", version, "Customer", "CustomerUpdated", map[string]string{"Name": "John Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func attributes(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestPublishSpans(t *testing.T) {
+	store, exporter := newStore()
+	ctx := context.Background()
+
+	if err := store.Publish(newEvent(t, 1), ctx); err != nil {
+		t.Fatal(err)
+	}
+	spans := exporter.GetSpans()
+
+	expected := []string{"EventStoreReader.GetLastEvent", "EventStoreWriter.AppendLastEvent", "EventStore.Publish"}
+	if names := spanNames(spans); len(names) != len(expected) {
+		t.Fatalf("expected spans %v, got %v", expected, names)
+	}
+	for i, name := range expected {
+		if spans[i].Name != name {
+			t.Errorf("expected span %s, got %s", name, spans[i].Name)
+		}
+	}
+
+	publish := spans[2]
+	attrs := attributes(publish)
+	if attrs[tracing.StreamIdKey].AsString() != "id" || attrs[tracing.VersionKey].AsInt64() != 1 ||
+		attrs[tracing.EntityKey].AsString() != "Customer" || attrs[tracing.ActionKey].AsString() != "CustomerUpdated" {
+		t.Errorf("unexpected attributes %v", publish.Attributes)
+	}
+	for _, s := range spans[:2] {
+		if s.Parent.SpanID() != publish.SpanContext.SpanID() {
+			t.Errorf("expected %s to be a child of the publish span", s.Name)
+		}
+	}
+}
+
+func TestLoadEventsSpans(t *testing.T) {
+	store, exporter := newStore()
+	ctx := context.Background()
+
+	for v := 1; v <= 3; v++ {
+		if err := store.Publish(newEvent(t, v), ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exporter.Reset()
+
+	if _, err := store.LoadEvents("id", 0, ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range exporter.GetSpans() {
+		attrs := attributes(s)
+		switch s.Name {
+		case "EventStore.LoadEvents":
+			if attrs[tracing.EventCountKey].AsInt64() != 3 {
+				t.Errorf("expected 3 events, got %v", s.Attributes)
+			}
+		case "EventStoreReader.GetEvents":
+			if attrs[tracing.EventCountKey].AsInt64() != 2 {
+				t.Errorf("expected 2 events before the last one, got %v", s.Attributes)
+			}
+		case "EventStoreReader.GetLastEvent":
+			if attrs[tracing.VersionKey].AsInt64() != 3 {
+				t.Errorf("expected the last event to be read, got %v", s.Attributes)
+			}
+		default:
+			t.Errorf("unexpected span %s", s.Name)
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+	store, exporter := newStore()
+	ctx := context.Background()
+
+	err := store.PublishBatch([]*core.Event{newEvent(t, 2), newEvent(t, 3)}, 1, ctx)
+	if !errors.Is(err, core.ConcurrencyError{}) {
+		t.Fatalf("expected a concurrency error, got %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	batch := spans[len(spans)-1]
+	if batch.Name != "EventStore.PublishBatch" || batch.Status.Code != codes.Error || len(batch.Events) == 0 {
+		t.Errorf("expected the error to be recorded, got %+v", batch)
+	}
+	attrs := attributes(batch)
+	if attrs[tracing.ExpectedVersionKey].AsInt64() != 1 || attrs[tracing.EventCountKey].AsInt64() != 2 {
+		t.Errorf("unexpected attributes %v", batch.Attributes)
+	}
+}
+
+func TestCachedLoadReadsLastEvent(t *testing.T) {
+	store, exporter := newStore()
+	repo := eventstore.NewRepository(store, func() *customer.Customer {
+		return &customer.Customer{}
+	}, eventstore.WithCache(eventstore.NewAggregateCache(10)))
+	ctx := context.Background()
+
+	created, _ := core.NewEvent("id", 1, "Customer", customer.CustomerCreated, customer.CustomerCreatedEvent{Name: "John Doe"})
+	if err := repo.Save(repo.New(), []*core.Event{created}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	exporter.Reset()
+
+	if _, err := repo.Load("id", ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"EventStoreReader.GetLastEvent", "EventStore.LoadNewEvents"}
+	names := spanNames(exporter.GetSpans())
+	if len(names) != len(expected) || names[0] != expected[0] || names[1] != expected[1] {
+		t.Errorf("expected spans %v, got %v", expected, names)
+	}
+}
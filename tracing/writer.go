@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/akkgr/eventstore/core"
+	"github.com/akkgr/eventstore/eventstore"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Writer traces the calls to an event store writer.
+// It implements eventstore.EventStoreTransactWriter, with AppendEvent and
+// UpdateLastEvent if the writer it wraps does not, and
+// eventstore.EventStoreBatchWriter, returning core.NotSupported if the writer
+// it wraps does not.
+type Writer struct {
+	writer eventstore.EventStoreWriter
+	tracer trace.Tracer
+}
+
+func NewWriter(w eventstore.EventStoreWriter, opts ...Option) *Writer {
+	return &Writer{writer: w, tracer: newTracer(opts)}
+}
+
+func (w *Writer) AppendLastEvent(e *core.Event, c context.Context) error {
+	c, s := start(w.tracer, c, "EventStoreWriter.AppendLastEvent", trace.SpanKindClient, eventAttributes(e)...)
+	err := w.writer.AppendLastEvent(e, c)
+	s.end(err)
+	return err
+}
+
+func (w *Writer) UpdateLastEvent(e *core.Event, c context.Context) error {
+	c, s := start(w.tracer, c, "EventStoreWriter.UpdateLastEvent", trace.SpanKindClient, eventAttributes(e)...)
+	err := w.writer.UpdateLastEvent(e, c)
+	s.end(err)
+	return err
+}
+
+func (w *Writer) AppendEvent(e *core.Event, c context.Context) error {
+	c, s := start(w.tracer, c, "EventStoreWriter.AppendEvent", trace.SpanKindClient, eventAttributes(e)...)
+	err := w.writer.AppendEvent(e, c)
+	s.end(err)
+	return err
+}
+
+func (w *Writer) TransactLastEvent(last *core.Event, e *core.Event, c context.Context) error {
+	tw, ok := w.writer.(eventstore.EventStoreTransactWriter)
+	if !ok {
+		if err := w.AppendEvent(last, c); err != nil {
+			return err
+		}
+		return w.UpdateLastEvent(e, c)
+	}
+
+	attrs := append(eventAttributes(e), ExpectedVersionKey.Int(last.Version))
+	c, s := start(w.tracer, c, "EventStoreWriter.TransactLastEvent", trace.SpanKindClient, attrs...)
+	err := tw.TransactLastEvent(last, e, c)
+	s.end(err)
+	return err
+}
+
+func (w *Writer) AppendEvents(last *core.Event, events []*core.Event, c context.Context) error {
+	bw, ok := w.writer.(eventstore.EventStoreBatchWriter)
+	if !ok {
+		return core.NotSupported{Operation: "batch append"}
+	}
+
+	expected := 0
+	if last != nil {
+		expected = last.Version
+	}
+	c, s := start(w.tracer, c, "EventStoreWriter.AppendEvents", trace.SpanKindClient, batchAttributes(events, expected)...)
+	err := bw.AppendEvents(last, events, c)
+	s.end(err)
+	return err
+}